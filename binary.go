@@ -0,0 +1,168 @@
+package pam
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// BinaryMessageHeaderSize is the size of the header of a BinaryMessage: a
+// 4 bytes length followed by the control byte.
+const BinaryMessageHeaderSize = 5
+
+// Binary message control values, as defined by libpamc.
+const (
+	// BinaryControlFalse is the false boolean value.
+	BinaryControlFalse uint8 = 0x00
+	// BinaryControlTrue is the true boolean value.
+	BinaryControlTrue uint8 = 0x01
+	// BinaryControlOK indicates the successful completion of a request.
+	BinaryControlOK uint8 = 0x02
+	// BinaryControlSelect selects the agent handling the conversation.
+	BinaryControlSelect uint8 = 0x03
+	// BinaryControlDone indicates that the exchange is complete.
+	BinaryControlDone uint8 = 0x04
+	// BinaryControlFail indicates that a request failed.
+	BinaryControlFail uint8 = 0x05
+)
+
+// BinaryMessage is a message of a binary conversation, following the
+// convention of Linux-PAM libpamc: the data is preceded by a header
+// containing the total length of the message, as a 4 bytes big-endian
+// integer, and a control byte that identifies the kind of message.
+type BinaryMessage struct {
+	// Control is the control byte of the message.
+	Control uint8
+	// Data is the payload of the message.
+	Data []byte
+}
+
+// ReadBinaryMessage reads a binary message from the data of a binary
+// conversation. The returned message holds a copy of the data, so it's
+// safe to use it once the conversation is completed.
+func ReadBinaryMessage(p BinaryPointer) (BinaryMessage, error) {
+	if p == nil {
+		return BinaryMessage{}, fmt.Errorf("%w: empty binary message", ErrConv)
+	}
+	// #nosec:G103 - the length is checked before accessing the data.
+	header := unsafe.Slice((*byte)(p), BinaryMessageHeaderSize)
+	length := binary.BigEndian.Uint32(header)
+	if length < BinaryMessageHeaderSize || length > math.MaxInt32 {
+		return BinaryMessage{}, fmt.Errorf("%w: invalid binary message length %d",
+			ErrConv, length)
+	}
+	var m BinaryMessage
+	// #nosec:G103 - the length is provided by the module.
+	err := m.UnmarshalBinary(unsafe.Slice((*byte)(p), length))
+	return m, err
+}
+
+// UnmarshalBinary decodes the message from its binary representation.
+func (m *BinaryMessage) UnmarshalBinary(b []byte) error {
+	if len(b) < BinaryMessageHeaderSize {
+		return fmt.Errorf("%w: binary message too short", ErrConv)
+	}
+	length := binary.BigEndian.Uint32(b)
+	if length < BinaryMessageHeaderSize || uint64(length) > uint64(len(b)) {
+		return fmt.Errorf("%w: invalid binary message length %d", ErrConv, length)
+	}
+	m.Control = b[4]
+	m.Data = append([]byte(nil), b[BinaryMessageHeaderSize:length]...)
+	return nil
+}
+
+// MarshalBinary encodes the message to its binary representation.
+func (m BinaryMessage) MarshalBinary() ([]byte, error) {
+	length := BinaryMessageHeaderSize + len(m.Data)
+	if length > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: binary message too long", ErrConv)
+	}
+	b := make([]byte, length)
+	binary.BigEndian.PutUint32(b, uint32(length))
+	b[4] = m.Control
+	copy(b[BinaryMessageHeaderSize:], m.Data)
+	return b, nil
+}
+
+// BinaryMessageHandler is an interface for objects that can handle the
+// messages of a binary protocol.
+type BinaryMessageHandler interface {
+	// RespondPAMBinaryMessage receives a binary message and returns the
+	// reply to send back to the module.
+	RespondPAMBinaryMessage(BinaryMessage) (BinaryMessage, error)
+}
+
+// BinaryMessageHandlerFunc is an adapter to allow the use of ordinary
+// functions as binary message handlers.
+type BinaryMessageHandlerFunc func(BinaryMessage) (BinaryMessage, error)
+
+// RespondPAMBinaryMessage is a binary message handler adapter.
+func (f BinaryMessageHandlerFunc) RespondPAMBinaryMessage(m BinaryMessage) (BinaryMessage, error) {
+	return f(m)
+}
+
+// BinaryProtocols is a registry of binary message handlers, keyed by the
+// control byte of the messages they handle.
+//
+// It decodes the binary messages sent by the modules, dispatches them to
+// the registered handler and encodes the replies, so it can be used to
+// implement BinaryConversationHandler.RespondPAMBinary.
+type BinaryProtocols struct {
+	mu       sync.RWMutex
+	handlers map[uint8]BinaryMessageHandler
+}
+
+// Register sets the handler for the messages with the given control byte,
+// replacing the previous one, if any. A nil handler removes it.
+func (p *BinaryProtocols) Register(control uint8, h BinaryMessageHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h == nil {
+		delete(p.handlers, control)
+		return
+	}
+	if p.handlers == nil {
+		p.handlers = make(map[uint8]BinaryMessageHandler)
+	}
+	p.handlers[control] = h
+}
+
+// RespondPAMBinary decodes the binary message and replies with the response
+// of the handler registered for its control byte.
+func (p *BinaryProtocols) RespondPAMBinary(ptr BinaryPointer) ([]byte, error) {
+	m, err := ReadBinaryMessage(ptr)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	h, ok := p.handlers[m.Control]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported binary message control 0x%02x",
+			ErrConv, m.Control)
+	}
+	reply, err := h.RespondPAMBinaryMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	return reply.MarshalBinary()
+}
+
+// BinaryConversation is a BinaryConversationHandler that handles the
+// textual messages with a ConversationHandler and the binary ones with the
+// registered protocols.
+type BinaryConversation struct {
+	ConversationHandler
+	// Protocols handles the binary messages.
+	Protocols *BinaryProtocols
+}
+
+// RespondPAMBinary handles the binary messages using Protocols.
+func (c BinaryConversation) RespondPAMBinary(ptr BinaryPointer) ([]byte, error) {
+	if c.Protocols == nil {
+		return nil, fmt.Errorf("%w: no binary protocols", ErrConv)
+	}
+	return c.Protocols.RespondPAMBinary(ptr)
+}
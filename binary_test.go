@@ -0,0 +1,97 @@
+package pam
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestBinaryMessage(t *testing.T) {
+	t.Parallel()
+	m := BinaryMessage{Control: BinaryControlSelect, Data: []byte("agent")}
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal #error: %v", err)
+	}
+	if !bytes.Equal(b, []byte("\x00\x00\x00\x0a\x03agent")) {
+		t.Fatalf("marshal #unexpected data: %q", b)
+	}
+
+	// Trailing data must be ignored.
+	b = append(b, 0xff)
+	var m2 BinaryMessage
+	if err := m2.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unmarshal #error: %v", err)
+	}
+	if m2.Control != BinaryControlSelect || string(m2.Data) != "agent" {
+		t.Fatalf("unmarshal #unexpected message: %#v", m2)
+	}
+
+	m3, err := ReadBinaryMessage(BinaryPointer(unsafe.Pointer(&b[0])))
+	if err != nil {
+		t.Fatalf("read #error: %v", err)
+	}
+	if m3.Control != BinaryControlSelect || string(m3.Data) != "agent" {
+		t.Fatalf("read #unexpected message: %#v", m3)
+	}
+}
+
+func TestBinaryMessage_Invalid(t *testing.T) {
+	t.Parallel()
+	invalid := [][]byte{
+		nil,
+		[]byte("\x00\x00\x00"),
+		[]byte("\x00\x00\x00\x04\x01"),
+		[]byte("\x00\x00\x00\x07\x01a"),
+	}
+	for _, b := range invalid {
+		var m BinaryMessage
+		if err := m.UnmarshalBinary(b); !errors.Is(err, ErrConv) {
+			t.Fatalf("unmarshal #unexpected error for %q: %v", b, err)
+		}
+	}
+	b := []byte("\x00\x00\x00\x02\x01")
+	if _, err := ReadBinaryMessage(BinaryPointer(unsafe.Pointer(&b[0]))); !errors.Is(err, ErrConv) {
+		t.Fatalf("read #unexpected error: %v", err)
+	}
+	if _, err := ReadBinaryMessage(nil); !errors.Is(err, ErrConv) {
+		t.Fatalf("read #unexpected error: %v", err)
+	}
+}
+
+func TestBinaryProtocols(t *testing.T) {
+	t.Parallel()
+	protocols := &BinaryProtocols{}
+	protocols.Register(0x42, BinaryMessageHandlerFunc(func(m BinaryMessage) (BinaryMessage, error) {
+		return BinaryMessage{Control: BinaryControlOK, Data: append(m.Data, "-pong"...)}, nil
+	}))
+	c := BinaryConversation{
+		ConversationHandler: StaticCredentials{},
+		Protocols:           protocols,
+	}
+	var _ BinaryConversationHandler = c
+
+	b, _ := BinaryMessage{Control: 0x42, Data: []byte("ping")}.MarshalBinary()
+	reply, err := c.RespondPAMBinary(BinaryPointer(unsafe.Pointer(&b[0])))
+	if err != nil {
+		t.Fatalf("respond #error: %v", err)
+	}
+	if !bytes.Equal(reply, []byte("\x00\x00\x00\x0e\x02ping-pong")) {
+		t.Fatalf("respond #unexpected reply: %q", reply)
+	}
+
+	b, _ = BinaryMessage{Control: 0x43}.MarshalBinary()
+	if _, err := c.RespondPAMBinary(BinaryPointer(unsafe.Pointer(&b[0]))); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+
+	protocols.Register(0x42, nil)
+	b, _ = BinaryMessage{Control: 0x42}.MarshalBinary()
+	if _, err := c.RespondPAMBinary(BinaryPointer(unsafe.Pointer(&b[0]))); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+	if _, err := (BinaryConversation{}).RespondPAMBinary(BinaryPointer(unsafe.Pointer(&b[0]))); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package pam
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Implementation identifies a PAM implementation.
+type Implementation int
+
+// PAM implementations.
+const (
+	// UnknownImplementation is an implementation that can't be recognized.
+	UnknownImplementation Implementation = iota
+	// LinuxPAM is the Linux-PAM implementation.
+	LinuxPAM
+	// OpenPAM is the OpenPAM implementation, used by BSDs and macOS.
+	OpenPAM
+	// SolarisPAM is the Solaris and illumos implementation.
+	SolarisPAM
+)
+
+// String returns the name of the implementation.
+func (i Implementation) String() string {
+	switch i {
+	case LinuxPAM:
+		return "Linux-PAM"
+	case OpenPAM:
+		return "OpenPAM"
+	case SolarisPAM:
+		return "Solaris PAM"
+	default:
+		return "unknown"
+	}
+}
+
+// optionalFunctions are the library functions that are not provided by all
+// the PAM implementations or versions, and that are looked up at runtime.
+var optionalFunctions = []string{
+	"pam_start_confdir",
+	"pam_get_authtok",
+	"pam_fail_delay",
+}
+
+// OptionalFunctions returns the library functions that are not provided by
+// all the PAM implementations or versions, and that are looked up at runtime.
+func OptionalFunctions() []string {
+	return append([]string(nil), optionalFunctions...)
+}
+
+// CapabilityReport describes what the PAM library in use supports.
+type CapabilityReport struct {
+	// Implementation is the PAM implementation the package is built with.
+	Implementation Implementation
+	// Library is the path of the PAM library in use, if it can be found.
+	Library string
+	// Version is the version of the PAM library in use, as found in the
+	// name of its file, such as 0.85.1 for libpam.so.0.85.1. PAM libraries
+	// don't report their release at runtime, so it's the version of the
+	// shared library rather than the release one.
+	Version string
+	// HeadersVersion is the version declared by the PAM headers the package
+	// is built with, if any. For Linux-PAM it is the API version.
+	HeadersVersion string
+	// Items are the supported items.
+	Items []Item
+	// Errors are the supported errors.
+	Errors []Error
+	// Flags are the supported flags.
+	Flags []Flags
+	// BinaryPrompt reports whether PAM supports PAM_BINARY_PROMPT.
+	BinaryPrompt bool
+	// Functions maps each of the OptionalFunctions to whether the library
+	// provides it.
+	Functions map[string]bool
+}
+
+// HasItem reports whether the item is supported.
+func (c *CapabilityReport) HasItem(i Item) bool {
+	for _, item := range c.Items {
+		if item == i {
+			return true
+		}
+	}
+	return false
+}
+
+// HasError reports whether the error is supported.
+func (c *CapabilityReport) HasError(e Error) bool {
+	for _, err := range c.Errors {
+		if err == e {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFunction reports whether the library provides the named function.
+func (c *CapabilityReport) HasFunction(name string) bool {
+	if found, ok := c.Functions[name]; ok {
+		return found
+	}
+	return lookupSymbol(name) != nil
+}
+
+// Capabilities returns a report of what the PAM library in use supports.
+// Optional functions are resolved at runtime.
+func Capabilities() *CapabilityReport {
+	library := libraryPath()
+	c := &CapabilityReport{
+		Implementation: implementation,
+		Library:        library,
+		Version:        libraryVersion(library),
+		HeadersVersion: headersVersion(),
+		Items: append([]Item{
+			Service, User, Tty, Rhost, Authtok, Oldauthtok, Ruser, UserPrompt,
+		}, platformItems...),
+		Errors: append([]Error{
+			ErrOpen, ErrSymbol, ErrService, ErrSystem, ErrBuf, ErrPermDenied,
+			ErrAuth, ErrCredInsufficient, ErrAuthinfoUnavail, ErrUserUnknown,
+			ErrMaxtries, ErrNewAuthtokReqd, ErrAcctExpired, ErrSession,
+			ErrCredUnavail, ErrCredExpired, ErrCred, ErrNoModuleData, ErrConv,
+			ErrAuthtok, ErrAuthtokRecovery, ErrAuthtokLockBusy,
+			ErrAuthtokDisableAging, ErrTryAgain, ErrIgnore, ErrAbort,
+			ErrAuthtokExpired, ErrModuleUnknown,
+		}, platformErrors...),
+		Flags: []Flags{
+			Silent, DisallowNullAuthtok, EstablishCred, DeleteCred,
+			ReinitializeCred, RefreshCred, ChangeExpiredAuthtok,
+		},
+		BinaryPrompt: CheckPamHasBinaryProtocol(),
+		Functions:    make(map[string]bool, len(optionalFunctions)),
+	}
+
+	for _, name := range optionalFunctions {
+		c.Functions[name] = lookupSymbol(name) != nil
+	}
+	return c
+}
+
+// libraryVersion returns the version in the file name of a shared library,
+// such as 0.85.1 for libpam.so.0.85.1 or 2 for libpam.2.dylib.
+func libraryVersion(path string) string {
+	name := filepath.Base(path)
+	if _, version, found := strings.Cut(name, ".so."); found {
+		return version
+	}
+	if name, found := strings.CutSuffix(name, ".dylib"); found {
+		if _, version, found := strings.Cut(name, "."); found {
+			return version
+		}
+	}
+	return ""
+}
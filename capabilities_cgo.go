@@ -0,0 +1,81 @@
+package pam
+
+/*
+#define _GNU_SOURCE
+#include <dlfcn.h>
+#include <security/pam_appl.h>
+
+#if defined(__has_include)
+#if __has_include(<security/openpam.h>)
+#include <security/openpam.h>
+#endif
+#endif
+
+#if defined(__LINUX_PAM__)
+#define GO_PAM_IMPLEMENTATION 1
+#elif defined(OPENPAM)
+#define GO_PAM_IMPLEMENTATION 2
+#elif defined(__sun)
+#define GO_PAM_IMPLEMENTATION 3
+#else
+#define GO_PAM_IMPLEMENTATION 0
+#endif
+
+#if defined(__LINUX_PAM_MAJOR__) && defined(__LINUX_PAM_MINOR__)
+#define GO_PAM_VERSION_MAJOR __LINUX_PAM_MAJOR__
+#define GO_PAM_VERSION_MINOR __LINUX_PAM_MINOR__
+#else
+#define GO_PAM_VERSION_MAJOR 0
+#define GO_PAM_VERSION_MINOR 0
+#endif
+
+#ifdef OPENPAM_VERSION
+#define GO_OPENPAM_VERSION OPENPAM_VERSION
+#else
+#define GO_OPENPAM_VERSION 0
+#endif
+
+static const char *go_pam_library_path(void *symbol)
+{
+	Dl_info info;
+	if (!symbol || !dladdr(symbol, &info))
+		return NULL;
+	return info.dli_fname;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// implementation is the PAM implementation the package is built with.
+const implementation = Implementation(C.GO_PAM_IMPLEMENTATION)
+
+// headersVersion returns the version declared by the PAM headers, if any.
+func headersVersion() string {
+	switch {
+	case C.GO_OPENPAM_VERSION != 0:
+		return fmt.Sprint(C.GO_OPENPAM_VERSION)
+	case C.GO_PAM_VERSION_MAJOR != 0:
+		return fmt.Sprintf("%d.%d", C.GO_PAM_VERSION_MAJOR,
+			C.GO_PAM_VERSION_MINOR)
+	default:
+		return ""
+	}
+}
+
+// libraryPath returns the path of the PAM library in use, with the symbolic
+// links resolved, or an empty string if it can't be found.
+func libraryPath() string {
+	p := C.go_pam_library_path(lookupSymbol("pam_start"))
+	if p == nil {
+		return ""
+	}
+	path := C.GoString(p)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
@@ -0,0 +1,94 @@
+//go:build cgo
+
+package pam
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+	c := Capabilities()
+
+	if runtime.GOOS == "linux" && c.Implementation != LinuxPAM {
+		t.Fatalf("capabilities #unexpected implementation: %v", c.Implementation)
+	}
+	if c.Implementation == LinuxPAM && (c.Library == "" || c.Version == "" ||
+		c.HeadersVersion == "") {
+		t.Fatalf("capabilities #expected versions: %q, %q, %q", c.Library,
+			c.Version, c.HeadersVersion)
+	}
+	if c.Version != libraryVersion(c.Library) {
+		t.Fatalf("capabilities #unexpected version: %q", c.Version)
+	}
+	if !c.HasItem(Service) || !c.HasItem(UserPrompt) {
+		t.Fatalf("capabilities #expected common items: %v", c.Items)
+	}
+	if c.HasItem(Item(-1)) {
+		t.Fatalf("capabilities #unexpected item")
+	}
+	if !c.HasError(ErrAuth) || !c.HasError(ErrModuleUnknown) {
+		t.Fatalf("capabilities #expected common errors: %v", c.Errors)
+	}
+	if c.HasError(Error(-1)) {
+		t.Fatalf("capabilities #unexpected error")
+	}
+	if len(c.Flags) != 7 {
+		t.Fatalf("capabilities #unexpected flags: %v", c.Flags)
+	}
+	if c.BinaryPrompt != CheckPamHasBinaryProtocol() {
+		t.Fatalf("capabilities #unexpected binary prompt support")
+	}
+	if len(c.Functions) != len(OptionalFunctions()) {
+		t.Fatalf("capabilities #unexpected functions: %v", c.Functions)
+	}
+	if c.HasFunction("pam_start_confdir") != CheckPamHasStartConfdir() {
+		t.Fatalf("capabilities #unexpected pam_start_confdir support")
+	}
+	if !c.HasFunction("pam_start") {
+		t.Fatalf("capabilities #expected pam_start")
+	}
+	if c.HasFunction("pam_does_not_exist") {
+		t.Fatalf("capabilities #unexpected function")
+	}
+}
+
+func TestOptionalFunctions(t *testing.T) {
+	t.Parallel()
+	functions := OptionalFunctions()
+	functions[0] = "modified"
+	if OptionalFunctions()[0] == "modified" {
+		t.Fatalf("optional functions #unexpected shared slice")
+	}
+}
+
+func TestLibraryVersion(t *testing.T) {
+	t.Parallel()
+	for path, version := range map[string]string{
+		"/usr/lib/x86_64-linux-gnu/libpam.so.0.85.1": "0.85.1",
+		"/usr/lib/libpam.so.6":                       "6",
+		"/usr/lib/libpam.2.dylib":                    "2",
+		"/usr/lib/libpam.so":                         "",
+		"":                                           "",
+	} {
+		if v := libraryVersion(path); v != version {
+			t.Fatalf("library version #unexpected value for %q: %q", path, v)
+		}
+	}
+}
+
+func TestImplementationString(t *testing.T) {
+	t.Parallel()
+	for i, name := range map[Implementation]string{
+		UnknownImplementation: "unknown",
+		LinuxPAM:              "Linux-PAM",
+		OpenPAM:               "OpenPAM",
+		SolarisPAM:            "Solaris PAM",
+		Implementation(99):    "unknown",
+	} {
+		if i.String() != name {
+			t.Fatalf("string #unexpected value: %q", i.String())
+		}
+	}
+}
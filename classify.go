@@ -0,0 +1,142 @@
+package pam
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// PromptKind is the meaning of a conversation prompt, it can be used by user
+// interfaces to render the proper input type.
+type PromptKind int
+
+// Conversation prompt kinds.
+const (
+	// PromptGeneric is a prompt whose meaning is unknown.
+	PromptGeneric PromptKind = iota
+	// PromptUsername asks for the user name.
+	PromptUsername
+	// PromptPassword asks for the (current) password.
+	PromptPassword
+	// PromptNewPassword asks for a new password.
+	PromptNewPassword
+	// PromptConfirmNewPassword asks to retype the new password.
+	PromptConfirmNewPassword
+	// PromptOTP asks for a one-time password or a verification code.
+	PromptOTP
+	// PromptPIN asks for a PIN.
+	PromptPIN
+)
+
+var promptKindNames = map[PromptKind]string{
+	PromptGeneric:            "generic",
+	PromptUsername:           "username",
+	PromptPassword:           "password",
+	PromptNewPassword:        "new password",
+	PromptConfirmNewPassword: "confirm new password",
+	PromptOTP:                "otp",
+	PromptPIN:                "pin",
+}
+
+// String returns the name of the prompt kind.
+func (k PromptKind) String() string {
+	if name, ok := promptKindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Keywords used by the most common modules, in various languages. Go regular
+// expressions only handle ASCII word boundaries, so non-ASCII keywords are
+// matched as substrings.
+var (
+	promptConfirmRe = regexp.MustCompile(`(?i)\b(retype|re-type|re-enter|reenter|repeat|again|confirm|wiederholen|erneut|bestätig|retapez|confirmez|répétez|repita|repetir|confirme|ripeti|conferma|redigite|opnieuw|igen)|повтор|подтверд|再次|确认|もう一度|再入力`)
+	promptPINRe     = regexp.MustCompile(`(?i)\bpin\b`)
+	promptOTPRe     = regexp.MustCompile(`(?i)\b(otp|one[- ]time|verification|passcode|token|code|2fa|two[- ]factor|authenticator|bestätigungscode|verifizierungscode|código|codice|kod)\b|код|验证码|確認コード`)
+	promptNewRe     = regexp.MustCompile(`(?i)\b(new|neues|neue|neu|nouveau|nouvel|nouvelle|nuevo|nueva|novo|nova|nuovo|nuova|nieuw|nieuwe|nytt|ny)\b|новый|новое|新`)
+	promptPasswdRe  = regexp.MustCompile(`(?i)(password|passwort|kennwort|passphrase|mot de passe|contraseña|senha|parola d'ordine|wachtwoord|lösenord|adgangskode|salasana|hasło|heslo|пароль|密码|密碼|パスワード|암호|비밀번호)`)
+	promptUserRe    = regexp.MustCompile(`(?i)\b(login|user|username|user name|benutzer|benutzername|utilisateur|identifiant|usuario|usuário|utente|gebruiker|användare)\b|пользовател|логин|用户|用戶|ユーザー`)
+)
+
+// PromptClassifier maps conversation messages to their PromptKind.
+//
+// The classification uses heuristics based on keywords commonly used by
+// modules in various languages, that can be overridden for specific
+// messages.
+type PromptClassifier struct {
+	// Overrides maps messages to their kind, it takes precedence over the
+	// heuristics. The keys are compared ignoring case, surrounding
+	// spaces and trailing colons.
+	Overrides map[string]PromptKind
+}
+
+// Classify returns the kind of the message. Messages that are not prompts
+// are always PromptGeneric.
+func (c *PromptClassifier) Classify(s Style, msg string) PromptKind {
+	if s != PromptEchoOff && s != PromptEchoOn {
+		return PromptGeneric
+	}
+	if c != nil && len(c.Overrides) > 0 {
+		normalized := normalizePrompt(msg)
+		for m, kind := range c.Overrides {
+			if normalizePrompt(m) == normalized {
+				return kind
+			}
+		}
+	}
+
+	if loc := promptPasswdRe.FindStringIndex(msg); loc != nil {
+		return classifyPasswordPrompt(msg[:loc[0]], msg[loc[1]:])
+	}
+	switch {
+	case promptPINRe.MatchString(msg):
+		return PromptPIN
+	case promptConfirmRe.MatchString(msg) && s == PromptEchoOff:
+		return PromptConfirmNewPassword
+	case promptNewRe.MatchString(msg) && s == PromptEchoOff:
+		return PromptNewPassword
+	case promptOTPRe.MatchString(msg):
+		return PromptOTP
+	case s == PromptEchoOn && promptUserRe.MatchString(msg):
+		return PromptUsername
+	case s == PromptEchoOff:
+		return PromptPassword
+	}
+	return PromptGeneric
+}
+
+// classifyPasswordPrompt returns the kind of a prompt mentioning a password,
+// from the words before the keyword and the one following it, where some
+// languages put the verb. The rest of the prompt is ignored, as it usually
+// holds a user name or a principal, such as in "Password for new.admin: ".
+func classifyPasswordPrompt(before, after string) PromptKind {
+	var next string
+	if words := strings.FieldsFunc(after, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	}); len(words) > 0 {
+		next = words[0]
+	}
+	switch {
+	case promptConfirmRe.MatchString(before), promptConfirmRe.MatchString(next):
+		return PromptConfirmNewPassword
+	case promptNewRe.MatchString(before):
+		return PromptNewPassword
+	case promptOTPRe.MatchString(before):
+		return PromptOTP
+	case promptPINRe.MatchString(before):
+		return PromptPIN
+	}
+	return PromptPassword
+}
+
+func normalizePrompt(msg string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimRight(strings.TrimSpace(msg), ":")))
+}
+
+var defaultPromptClassifier PromptClassifier
+
+// ClassifyPrompt returns the kind of the message using the default
+// heuristics.
+func ClassifyPrompt(s Style, msg string) PromptKind {
+	return defaultPromptClassifier.Classify(s, msg)
+}
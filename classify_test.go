@@ -0,0 +1,86 @@
+package pam
+
+import (
+	"testing"
+)
+
+func TestClassifyPrompt(t *testing.T) {
+	t.Parallel()
+	prompts := []struct {
+		style    Style
+		msg      string
+		expected PromptKind
+	}{
+		{PromptEchoOn, "login: ", PromptUsername},
+		{PromptEchoOn, "Username: ", PromptUsername},
+		{PromptEchoOn, "Benutzername: ", PromptUsername},
+		{PromptEchoOff, "Password: ", PromptPassword},
+		{PromptEchoOff, "Current password: ", PromptPassword},
+		{PromptEchoOff, "(current) UNIX password: ", PromptPassword},
+		{PromptEchoOff, "Password for test@EXAMPLE.COM: ", PromptPassword},
+		{PromptEchoOff, "Passwort: ", PromptPassword},
+		{PromptEchoOff, "Mot de passe : ", PromptPassword},
+		{PromptEchoOff, "Пароль: ", PromptPassword},
+		{PromptEchoOff, "New password: ", PromptNewPassword},
+		{PromptEchoOff, "Enter new password: ", PromptNewPassword},
+		{PromptEchoOff, "Neues Passwort: ", PromptNewPassword},
+		{PromptEchoOff, "Nouveau mot de passe : ", PromptNewPassword},
+		{PromptEchoOff, "Nueva contraseña: ", PromptNewPassword},
+		{PromptEchoOff, "Новый пароль: ", PromptNewPassword},
+		{PromptEchoOff, "Retype new password: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "Reenter new Password: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "Enter it again: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "Neues Passwort wiederholen: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "Повторите ввод нового пароля: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "Enter password again: ", PromptConfirmNewPassword},
+		{PromptEchoOff, "New password (again): ", PromptConfirmNewPassword},
+		{PromptEchoOff, "[sudo] password for new.admin: ", PromptPassword},
+		{PromptEchoOff, "Password for user code: ", PromptPassword},
+		{PromptEchoOff, "Password for pin: ", PromptPassword},
+		{PromptEchoOff, "Password for again@EXAMPLE.COM: ", PromptPassword},
+		{PromptEchoOn, "Password for new.admin: ", PromptPassword},
+		{PromptEchoOff, "Verification code: ", PromptOTP},
+		{PromptEchoOn, "Verification code: ", PromptOTP},
+		{PromptEchoOff, "One-time password (OATH) for `test': ", PromptOTP},
+		{PromptEchoOn, "Enter passcode: ", PromptOTP},
+		{PromptEchoOff, "PIN for smart card: ", PromptPIN},
+		{PromptEchoOff, "Enter answer: ", PromptPassword},
+		{PromptEchoOn, "Enter answer: ", PromptGeneric},
+		{TextInfo, "Password: ", PromptGeneric},
+		{ErrorMsg, "Verification code: ", PromptGeneric},
+	}
+	for _, p := range prompts {
+		if kind := ClassifyPrompt(p.style, p.msg); kind != p.expected {
+			t.Errorf("classify #%q: expected %v, got %v", p.msg, p.expected, kind)
+		}
+	}
+}
+
+func TestPromptClassifier_Overrides(t *testing.T) {
+	t.Parallel()
+	c := PromptClassifier{
+		Overrides: map[string]PromptKind{
+			"yubikey for `test'": PromptOTP,
+			"Enter answer":       PromptGeneric,
+		},
+	}
+	if kind := c.Classify(PromptEchoOff, "YubiKey for `test': "); kind != PromptOTP {
+		t.Fatalf("classify #unexpected kind: %v", kind)
+	}
+	if kind := c.Classify(PromptEchoOff, "Enter answer: "); kind != PromptGeneric {
+		t.Fatalf("classify #unexpected kind: %v", kind)
+	}
+	if kind := c.Classify(PromptEchoOff, "Password: "); kind != PromptPassword {
+		t.Fatalf("classify #unexpected kind: %v", kind)
+	}
+}
+
+func TestPromptKind_String(t *testing.T) {
+	t.Parallel()
+	if s := PromptConfirmNewPassword.String(); s != "confirm new password" {
+		t.Fatalf("string #unexpected: %v", s)
+	}
+	if s := PromptKind(-1).String(); s != "unknown" {
+		t.Fatalf("string #unexpected: %v", s)
+	}
+}
@@ -0,0 +1,107 @@
+package pam
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Style is the type of message that the conversation handler should display.
+type Style int
+
+// String returns the name of the style.
+func (s Style) String() string {
+	switch s {
+	case PromptEchoOff:
+		return "PROMPT_ECHO_OFF"
+	case PromptEchoOn:
+		return "PROMPT_ECHO_ON"
+	case ErrorMsg:
+		return "ERROR_MSG"
+	case TextInfo:
+		return "TEXT_INFO"
+	case BinaryPrompt:
+		return "BINARY_PROMPT"
+	default:
+		return fmt.Sprintf("Style(%d)", int(s))
+	}
+}
+
+// ConversationHandler is an interface for objects that can be used as
+// conversation callbacks during PAM authentication.
+type ConversationHandler interface {
+	// RespondPAM receives a message style and a message string. If the
+	// message Style is PromptEchoOff or PromptEchoOn then the function
+	// should return a response string.
+	RespondPAM(Style, string) (string, error)
+}
+
+// BinaryPointer exposes the type used for the data in a binary conversation.
+// It represents a pointer to data that is produced by the module and must be
+// parsed depending on the protocol in use.
+type BinaryPointer unsafe.Pointer
+
+// BinaryConversationHandler is an interface for objects that can be used as
+// conversation callbacks during PAM authentication if binary protocol is going
+// to be supported.
+type BinaryConversationHandler interface {
+	ConversationHandler
+	// RespondPAMBinary receives a pointer to the binary message. It's up to
+	// the receiver to parse it according to the protocol specifications.
+	// The function can return a byte array that will passed as pointer back
+	// to the module.
+	RespondPAMBinary(BinaryPointer) ([]byte, error)
+}
+
+// Message is a message sent by a module to the conversation handler.
+type Message struct {
+	Style Style
+	Msg   string
+}
+
+// BatchConversationHandler is an interface for conversation handlers that
+// answer all the messages that a module sends at once, for example to show
+// all its prompts in a single form.
+type BatchConversationHandler interface {
+	ConversationHandler
+	// RespondPAMBatch receives the messages and returns a response for
+	// each of them, which is ignored for TextInfo and ErrorMsg messages.
+	// Conversations including a BinaryPrompt are instead handled one
+	// message at a time using RespondPAM.
+	RespondPAMBatch([]Message) ([]string, error)
+}
+
+// ConversationFunc is an adapter to allow the use of ordinary functions as
+// conversation callbacks.
+type ConversationFunc func(Style, string) (string, error)
+
+// RespondPAM is a conversation callback adapter.
+func (f ConversationFunc) RespondPAM(s Style, msg string) (string, error) {
+	return f(s, msg)
+}
+
+// callbacks holds the Go functions invoked by PAM, it's passed to PAM as the
+// application data through a cgo.Handle.
+type callbacks struct {
+	handler   ConversationHandler
+	failDelay atomic.Pointer[func(Error, time.Duration)]
+	logger    atomic.Pointer[slog.Logger]
+	// current is the operation in progress, it's set by the goroutine
+	// performing it and read by the conversation callbacks.
+	current atomic.Pointer[operationRun]
+
+	mu    sync.Mutex
+	ctx   context.Context
+	hooks []Hooks
+	// ended is whether the transaction has ended, no hooks can be added
+	// then.
+	ended bool
+	// batch are the responses of a BatchConversationHandler that are yet
+	// to be returned, it's only accessed by the goroutine performing the
+	// operation.
+	batch []string
+}
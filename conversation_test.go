@@ -0,0 +1,262 @@
+//go:build cgo
+
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type conversationMessage struct {
+	style Style
+	msg   string
+}
+
+// recordingConversation answers the prompts with preset credentials, and
+// the ping binary messages with a pong, recording all the messages.
+type recordingConversation struct {
+	StaticCredentials
+	messages   []conversationMessage
+	failPrompt string
+	failBinary bool
+}
+
+func (c *recordingConversation) RespondPAM(s Style, msg string) (string, error) {
+	c.messages = append(c.messages, conversationMessage{s, msg})
+	if msg == c.failPrompt {
+		return "", errors.New("failing on purpose")
+	}
+	return c.StaticCredentials.RespondPAM(s, msg)
+}
+
+func (c *recordingConversation) RespondPAMBinary(p BinaryPointer) ([]byte, error) {
+	m, err := ReadBinaryMessage(p)
+	if err != nil {
+		return nil, err
+	}
+	c.messages = append(c.messages, conversationMessage{BinaryPrompt, string(m.Data)})
+	if c.failBinary {
+		return nil, errors.New("failing on purpose")
+	}
+	return BinaryMessage{Control: BinaryControlOK, Data: []byte("pong")}.MarshalBinary()
+}
+
+// startConversationTest starts a transaction on a service of test-services
+// using the pam_conv_test module, whose file name is replaced by the path
+// where the module is built.
+func startConversationTest(t *testing.T, service string, handler ConversationHandler) *Transaction {
+	t.Helper()
+
+	if !CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	module := buildTestModule(t, "pam_conv_test")
+	contents, err := os.ReadFile(filepath.Join("test-services", service))
+	if err != nil {
+		t.Fatalf("can't read service file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Replace(line, "pam_conv_test.so", module, 1)
+	}
+	confDir := writeTestService(t, service, lines...)
+
+	tx, err := StartConfDir(service, "testuser", handler, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	t.Cleanup(func() { maybeEndTransaction(t, tx) })
+	ensureTransactionEnds(t, tx)
+	return tx
+}
+
+func TestConversation_MultipleMessages(t *testing.T) {
+	t.Parallel()
+	c := &recordingConversation{
+		StaticCredentials: StaticCredentials{User: "testuser", Password: "secret"},
+	}
+	tx := startConversationTest(t, "conv-messages-service", c)
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+
+	expected := []conversationMessage{
+		{TextInfo, "An info message"},
+		{PromptEchoOn, "login: "},
+		{ErrorMsg, "An error message"},
+		{PromptEchoOff, "Password: "},
+	}
+	if fmt.Sprint(c.messages) != fmt.Sprint(expected) {
+		t.Fatalf("conversation #unexpected messages: %v", c.messages)
+	}
+}
+
+// batchConversation records the batches of messages it answers.
+type batchConversation struct {
+	recordingConversation
+	batches [][]Message
+	fail    bool
+	delay   time.Duration
+}
+
+func (c *batchConversation) RespondPAMBatch(messages []Message) ([]string, error) {
+	c.batches = append(c.batches, messages)
+	time.Sleep(c.delay)
+	if c.fail {
+		return nil, errors.New("failing on purpose")
+	}
+	responses := make([]string, len(messages))
+	for i, m := range messages {
+		r, err := c.StaticCredentials.RespondPAM(m.Style, m.Msg)
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = r
+	}
+	return responses, nil
+}
+
+func TestConversation_Batch(t *testing.T) {
+	t.Parallel()
+	c := &batchConversation{recordingConversation: recordingConversation{
+		StaticCredentials: StaticCredentials{User: "testuser", Password: "secret"},
+	}}
+	tx := startConversationTest(t, "conv-messages-service", c)
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	expected := [][]Message{{
+		{TextInfo, "An info message"},
+		{PromptEchoOn, "login: "},
+		{ErrorMsg, "An error message"},
+		{PromptEchoOff, "Password: "},
+	}}
+	if fmt.Sprint(c.batches) != fmt.Sprint(expected) || len(c.messages) != 0 {
+		t.Fatalf("conversation #unexpected messages: %v, %v", c.batches, c.messages)
+	}
+
+	c.fail = true
+	tx = startConversationTest(t, "conv-messages-service", c)
+	if err := tx.Authenticate(0); !errors.Is(err, ErrConv) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestConversation_WrongReply(t *testing.T) {
+	t.Parallel()
+	c := &recordingConversation{
+		StaticCredentials: StaticCredentials{User: "testuser", Password: "wrong"},
+	}
+	tx := startConversationTest(t, "conv-password-service", c)
+	if err := tx.Authenticate(0); !errors.Is(err, ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestConversation_Binary(t *testing.T) {
+	t.Parallel()
+	if !CheckPamHasBinaryProtocol() {
+		t.Skip("this requires PAM with binary protocol support")
+	}
+	c := &recordingConversation{
+		StaticCredentials: StaticCredentials{Password: "secret"},
+	}
+	tx := startConversationTest(t, "conv-binary-service", c)
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+
+	expected := []conversationMessage{
+		{TextInfo, "Binary follows"},
+		{BinaryPrompt, "ping"},
+		{PromptEchoOff, "Password: "},
+	}
+	if fmt.Sprint(c.messages) != fmt.Sprint(expected) {
+		t.Fatalf("conversation #unexpected messages: %v", c.messages)
+	}
+}
+
+func TestConversation_BinaryProtocols(t *testing.T) {
+	t.Parallel()
+	if !CheckPamHasBinaryProtocol() {
+		t.Skip("this requires PAM with binary protocol support")
+	}
+	protocols := &BinaryProtocols{}
+	protocols.Register(0x42, BinaryMessageHandlerFunc(func(m BinaryMessage) (BinaryMessage, error) {
+		return BinaryMessage{Control: BinaryControlDone, Data: append(m.Data, "-pong"...)}, nil
+	}))
+	c := BinaryConversation{
+		ConversationHandler: StaticCredentials{},
+		Protocols:           protocols,
+	}
+	tx := startConversationTest(t, "conv-binary-protocol-service", c)
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+
+	tx = startConversationTest(t, "conv-unknown-protocol-service", c)
+	if err := tx.Authenticate(0); !errors.Is(err, ErrConv) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestConversation_BinaryUnsupportedHandler(t *testing.T) {
+	t.Parallel()
+	tx := startConversationTest(t, "conv-binary-service", StaticCredentials{})
+	if err := tx.Authenticate(0); !errors.Is(err, ErrConv) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestConversation_ErrorCleanup(t *testing.T) {
+	t.Parallel()
+	if !CheckPamHasBinaryProtocol() {
+		t.Skip("this requires PAM with binary protocol support")
+	}
+
+	tests := map[string]struct {
+		conversation *recordingConversation
+		service      string
+		messages     int
+	}{
+		"prompt after binary": {
+			conversation: &recordingConversation{
+				StaticCredentials: StaticCredentials{User: "name", Password: "secret"},
+				failPrompt:        "Password: ",
+			},
+			service:  "conv-binary-first-service",
+			messages: 3,
+		},
+		"binary after prompts": {
+			conversation: &recordingConversation{
+				StaticCredentials: StaticCredentials{User: "name", Password: "secret"},
+				failBinary:        true,
+			},
+			service:  "conv-binary-last-service",
+			messages: 3,
+		},
+		"first message": {
+			conversation: &recordingConversation{failPrompt: "Password: "},
+			service:      "conv-password-service",
+			messages:     1,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			tx := startConversationTest(t, tc.service, tc.conversation)
+			if err := tx.Authenticate(0); !errors.Is(err, ErrConv) {
+				t.Fatalf("authenticate #unexpected error: %v", err)
+			}
+			if len(tc.conversation.messages) != tc.messages {
+				t.Fatalf("conversation #unexpected messages: %v", tc.conversation.messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// StaticCredentials is a non-interactive conversation handler that answers
+// PAM prompts with preset credentials.
+//
+// PromptEchoOn messages are answered with User and PromptEchoOff messages
+// with Password. Unless UserPrompt is set, only the PromptEchoOn messages
+// that ClassifyPrompt recognizes as asking for the user name, or can't
+// classify, are answered, and not the ones asking for a verification code
+// for example. When NewPassword is set, the PromptEchoOff messages that
+// match NewPasswordPrompt are answered with it, so that the same handler can
+// be used to drive ChangeAuthTok, where Password is the current password.
+// By default the new password prompts are recognized using ClassifyPrompt,
+// and the one-time password and PIN prompts are not answered with Password
+// unless PasswordPrompt matches them.
+//
+// Prompts that can't be answered, either because they don't match the
+// configured regular expressions or because there's no value to reply with,
+// cause the conversation to fail instead of sending a wrong reply.
+type StaticCredentials struct {
+	// User is the user name.
+	User string
+	// Password is the (current) password.
+	Password string
+	// NewPassword is the new password used during ChangeAuthTok.
+	NewPassword string
+
+	// UserPrompt, if set, must match the PromptEchoOn messages.
+	UserPrompt *regexp.Regexp
+	// PasswordPrompt, if set, must match the PromptEchoOff messages
+	// answered with Password.
+	PasswordPrompt *regexp.Regexp
+	// NewPasswordPrompt, if set, matches the PromptEchoOff messages
+	// answered with NewPassword.
+	NewPasswordPrompt *regexp.Regexp
+
+	// OnMessage, if set, receives the TextInfo and ErrorMsg messages.
+	OnMessage func(Style, string)
+}
+
+// RespondPAM handles PAM conversation messages.
+func (c StaticCredentials) RespondPAM(s Style, msg string) (string, error) {
+	switch s {
+	case PromptEchoOn:
+		if c.User == "" {
+			return "", fmt.Errorf("%w: no user for prompt %q", ErrConv, msg)
+		}
+		if c.UserPrompt != nil {
+			if !c.UserPrompt.MatchString(msg) {
+				return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+			}
+			return c.User, nil
+		}
+		switch ClassifyPrompt(s, msg) {
+		case PromptUsername, PromptGeneric:
+			return c.User, nil
+		}
+		return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+	case PromptEchoOff:
+		if c.NewPassword != "" && c.isNewPasswordPrompt(s, msg) {
+			return c.NewPassword, nil
+		}
+		if c.Password == "" {
+			return "", fmt.Errorf("%w: no password for prompt %q", ErrConv, msg)
+		}
+		if c.PasswordPrompt != nil {
+			if !c.PasswordPrompt.MatchString(msg) {
+				return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+			}
+			return c.Password, nil
+		}
+		switch ClassifyPrompt(s, msg) {
+		case PromptOTP, PromptPIN:
+			return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+		}
+		return c.Password, nil
+	case TextInfo, ErrorMsg:
+		if c.OnMessage != nil {
+			c.OnMessage(s, msg)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("%w: unexpected message style %d", ErrConv, s)
+}
+
+// OnPasswordExpired returns Password and NewPassword, so that Login can change
+// the expired password. It fails if there's no NewPassword.
+func (c StaticCredentials) OnPasswordExpired() (string, string, error) {
+	if c.NewPassword == "" {
+		return "", "", errors.New("no new password")
+	}
+	return c.Password, c.NewPassword, nil
+}
+
+func (c StaticCredentials) isNewPasswordPrompt(s Style, msg string) bool {
+	if c.NewPasswordPrompt != nil {
+		return c.NewPasswordPrompt.MatchString(msg)
+	}
+	switch ClassifyPrompt(s, msg) {
+	case PromptNewPassword, PromptConfirmNewPassword:
+		return true
+	}
+	return false
+}
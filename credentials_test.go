@@ -0,0 +1,153 @@
+//go:build cgo
+
+package pam
+
+import (
+	"errors"
+	"os/user"
+	"regexp"
+	"testing"
+)
+
+func TestStaticCredentials(t *testing.T) {
+	t.Parallel()
+	var messages []string
+	c := StaticCredentials{
+		User:        "test",
+		Password:    "secret",
+		NewPassword: "newsecret",
+		OnMessage: func(s Style, msg string) {
+			messages = append(messages, msg)
+		},
+	}
+
+	responses := []struct {
+		style    Style
+		msg      string
+		expected string
+	}{
+		{PromptEchoOn, "login: ", "test"},
+		{PromptEchoOff, "Password: ", "secret"},
+		{PromptEchoOff, "Current password: ", "secret"},
+		{PromptEchoOff, "New password: ", "newsecret"},
+		{PromptEchoOff, "Retype new password: ", "newsecret"},
+		{TextInfo, "info", ""},
+		{ErrorMsg, "error", ""},
+	}
+	for _, r := range responses {
+		resp, err := c.RespondPAM(r.style, r.msg)
+		if err != nil {
+			t.Fatalf("respond #error: %v", err)
+		}
+		if resp != r.expected {
+			t.Fatalf("respond #unexpected response for %q: %q", r.msg, resp)
+		}
+	}
+	if len(messages) != 2 || messages[0] != "info" || messages[1] != "error" {
+		t.Fatalf("messages #unexpected: %v", messages)
+	}
+
+	if _, err := c.RespondPAM(BinaryPrompt, ""); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestStaticCredentials_UnexpectedPrompt(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{
+		User:           "test",
+		Password:       "secret",
+		UserPrompt:     regexp.MustCompile(`^login: $`),
+		PasswordPrompt: regexp.MustCompile(`^Password: $`),
+	}
+	if _, err := c.RespondPAM(PromptEchoOn, "Verification code: "); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+	if _, err := c.RespondPAM(PromptEchoOff, "PIN: "); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+	if _, err := (StaticCredentials{}).RespondPAM(PromptEchoOn, "login: "); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestStaticCredentials_PasswordPrompts(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{Password: "secret"}
+	for _, msg := range []string{"Verification code: ", "PIN for smart card: "} {
+		if _, err := c.RespondPAM(PromptEchoOff, msg); !errors.Is(err, ErrConv) {
+			t.Fatalf("respond #unexpected error for %q: %v", msg, err)
+		}
+	}
+
+	c.PasswordPrompt = regexp.MustCompile(`^PIN`)
+	if r, err := c.RespondPAM(PromptEchoOff, "PIN for smart card: "); err != nil || r != "secret" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+
+	if _, err := (StaticCredentials{}).RespondPAM(PromptEchoOff, "Password: "); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestStaticCredentials_UserPrompts(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{User: "testuser"}
+	for _, msg := range []string{"login: ", "Username: ", "Enter answer: "} {
+		if r, err := c.RespondPAM(PromptEchoOn, msg); err != nil || r != "testuser" {
+			t.Fatalf("respond #unexpected result for %q: %q, %v", msg, r, err)
+		}
+	}
+	for _, msg := range []string{"Verification code: ", "Password: ", "PIN: "} {
+		if _, err := c.RespondPAM(PromptEchoOn, msg); !errors.Is(err, ErrConv) {
+			t.Fatalf("respond #unexpected error for %q: %v", msg, err)
+		}
+	}
+
+	c.UserPrompt = regexp.MustCompile(`^Verification`)
+	if r, err := c.RespondPAM(PromptEchoOn, "Verification code: "); err != nil || r != "testuser" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+	if _, err := c.RespondPAM(PromptEchoOn, "login: "); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestPAM_ConfDir_StaticCredentials(t *testing.T) {
+	if !CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	u, _ := user.Current()
+	var infoText string
+	c := StaticCredentials{
+		User: "testuser",
+		OnMessage: func(s Style, msg string) {
+			infoText = msg
+		},
+	}
+
+	tx, err := StartConfDir("echo-service", u.Username, c, "test-services")
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+	err = tx.Authenticate(0)
+	if err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if infoText != "This is an info message for user "+u.Username+" on echo-service" {
+		t.Fatalf("Unexpected info message: %v", infoText)
+	}
+
+	tx2, err := StartConfDir("succeed-if-user-test", "", c, "test-services")
+	defer maybeEndTransaction(t, tx2)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx2)
+	err = tx2.Authenticate(0)
+	if err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+}
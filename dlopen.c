@@ -0,0 +1,77 @@
+//go:build cgo && pam_dlopen
+
+#define _GNU_SOURCE
+#include <dlfcn.h>
+#include <pthread.h>
+#include <security/pam_appl.h>
+#include <stdio.h>
+#include <string.h>
+
+#ifndef GO_PAM_LIBRARY
+#define GO_PAM_LIBRARY "libpam.so.0"
+#endif
+
+struct go_pam_dl go_pam_dl;
+
+static pthread_once_t load_once = PTHREAD_ONCE_INIT;
+static void *library;
+static char load_error[256];
+
+static void load(void)
+{
+	void *handle = dlopen(GO_PAM_LIBRARY, RTLD_NOW | RTLD_GLOBAL);
+	if (!handle) {
+		snprintf(load_error, sizeof load_error, "%s", dlerror());
+		return;
+	}
+
+#define LOAD_SYMBOL(name) \
+	do { \
+		*(void **)&go_pam_dl.name = dlsym(handle, #name); \
+		if (!go_pam_dl.name) { \
+			snprintf(load_error, sizeof load_error, \
+				 "%s: undefined symbol: %s", GO_PAM_LIBRARY, #name); \
+			goto error; \
+		} \
+	} while (0)
+
+	LOAD_SYMBOL(pam_start);
+	LOAD_SYMBOL(pam_end);
+	LOAD_SYMBOL(pam_authenticate);
+	LOAD_SYMBOL(pam_setcred);
+	LOAD_SYMBOL(pam_acct_mgmt);
+	LOAD_SYMBOL(pam_open_session);
+	LOAD_SYMBOL(pam_close_session);
+	LOAD_SYMBOL(pam_chauthtok);
+	LOAD_SYMBOL(pam_set_item);
+	LOAD_SYMBOL(pam_get_item);
+	LOAD_SYMBOL(pam_strerror);
+	LOAD_SYMBOL(pam_putenv);
+	LOAD_SYMBOL(pam_getenv);
+	LOAD_SYMBOL(pam_getenvlist);
+#undef LOAD_SYMBOL
+
+	library = handle;
+	return;
+error:
+	memset(&go_pam_dl, 0, sizeof go_pam_dl);
+	dlclose(handle);
+}
+
+int go_pam_dl_load(void)
+{
+	pthread_once(&load_once, load);
+	return library != NULL;
+}
+
+const char *go_pam_dl_error(void)
+{
+	return load_error;
+}
+
+void *go_pam_dl_sym(const char *name)
+{
+	if (!go_pam_dl_load())
+		return NULL;
+	return dlsym(library, name);
+}
@@ -0,0 +1,238 @@
+/*
+ * Linux-PAM compatible application API, used when building with the
+ * pam_dlopen tag. Nothing is linked against libpam: the functions resolve
+ * their implementation when the library is loaded at runtime, failing with
+ * PAM_SYSTEM_ERR (or NULL) when it's not available.
+ */
+#ifndef GO_PAM_DLOPEN_PAM_APPL_H
+#define GO_PAM_DLOPEN_PAM_APPL_H
+
+#include <stddef.h>
+
+#define __LINUX_PAM__ 1
+#define __LINUX_PAM_MAJOR__ 1
+#define __LINUX_PAM_MINOR__ 0
+
+typedef struct pam_handle pam_handle_t;
+
+/* Return values. */
+#define PAM_SUCCESS 0
+#define PAM_OPEN_ERR 1
+#define PAM_SYMBOL_ERR 2
+#define PAM_SERVICE_ERR 3
+#define PAM_SYSTEM_ERR 4
+#define PAM_BUF_ERR 5
+#define PAM_PERM_DENIED 6
+#define PAM_AUTH_ERR 7
+#define PAM_CRED_INSUFFICIENT 8
+#define PAM_AUTHINFO_UNAVAIL 9
+#define PAM_USER_UNKNOWN 10
+#define PAM_MAXTRIES 11
+#define PAM_NEW_AUTHTOK_REQD 12
+#define PAM_ACCT_EXPIRED 13
+#define PAM_SESSION_ERR 14
+#define PAM_CRED_UNAVAIL 15
+#define PAM_CRED_EXPIRED 16
+#define PAM_CRED_ERR 17
+#define PAM_NO_MODULE_DATA 18
+#define PAM_CONV_ERR 19
+#define PAM_AUTHTOK_ERR 20
+#define PAM_AUTHTOK_RECOVERY_ERR 21
+#define PAM_AUTHTOK_LOCK_BUSY 22
+#define PAM_AUTHTOK_DISABLE_AGING 23
+#define PAM_TRY_AGAIN 24
+#define PAM_IGNORE 25
+#define PAM_ABORT 26
+#define PAM_AUTHTOK_EXPIRED 27
+#define PAM_MODULE_UNKNOWN 28
+#define PAM_BAD_ITEM 29
+#define PAM_CONV_AGAIN 30
+#define PAM_INCOMPLETE 31
+
+/* Flags. */
+#define PAM_SILENT 0x8000U
+#define PAM_DISALLOW_NULL_AUTHTOK 0x0001U
+#define PAM_ESTABLISH_CRED 0x0002U
+#define PAM_DELETE_CRED 0x0004U
+#define PAM_REINITIALIZE_CRED 0x0008U
+#define PAM_REFRESH_CRED 0x0010U
+#define PAM_CHANGE_EXPIRED_AUTHTOK 0x0020U
+
+/* Items. */
+#define PAM_SERVICE 1
+#define PAM_USER 2
+#define PAM_TTY 3
+#define PAM_RHOST 4
+#define PAM_CONV 5
+#define PAM_AUTHTOK 6
+#define PAM_OLDAUTHTOK 7
+#define PAM_RUSER 8
+#define PAM_USER_PROMPT 9
+#define PAM_FAIL_DELAY 10
+#define PAM_XDISPLAY 11
+#define PAM_XAUTHDATA 12
+#define PAM_AUTHTOK_TYPE 13
+
+/* Message styles. */
+#define PAM_PROMPT_ECHO_OFF 1
+#define PAM_PROMPT_ECHO_ON 2
+#define PAM_ERROR_MSG 3
+#define PAM_TEXT_INFO 4
+#define PAM_RADIO_TYPE 5
+#define PAM_BINARY_PROMPT 7
+
+#define PAM_MAX_NUM_MSG 32
+#define PAM_MAX_MSG_SIZE 512
+#define PAM_MAX_RESP_SIZE 512
+
+struct pam_message {
+	int msg_style;
+	const char *msg;
+};
+
+struct pam_response {
+	char *resp;
+	int resp_retcode;
+};
+
+struct pam_conv {
+	int (*conv)(int num_msg, const struct pam_message **msg,
+		    struct pam_response **resp, void *appdata_ptr);
+	void *appdata_ptr;
+};
+
+struct pam_xauth_data {
+	int namelen;
+	char *name;
+	int datalen;
+	char *data;
+};
+
+/* The library functions, resolved by go_pam_dl_load(). */
+struct go_pam_dl {
+	int (*pam_start)(const char *service_name, const char *user,
+			 const struct pam_conv *pam_conversation, pam_handle_t **pamh);
+	int (*pam_end)(pam_handle_t *pamh, int pam_status);
+	int (*pam_authenticate)(pam_handle_t *pamh, int flags);
+	int (*pam_setcred)(pam_handle_t *pamh, int flags);
+	int (*pam_acct_mgmt)(pam_handle_t *pamh, int flags);
+	int (*pam_open_session)(pam_handle_t *pamh, int flags);
+	int (*pam_close_session)(pam_handle_t *pamh, int flags);
+	int (*pam_chauthtok)(pam_handle_t *pamh, int flags);
+	int (*pam_set_item)(pam_handle_t *pamh, int item_type, const void *item);
+	int (*pam_get_item)(const pam_handle_t *pamh, int item_type, const void **item);
+	const char *(*pam_strerror)(pam_handle_t *pamh, int errnum);
+	int (*pam_putenv)(pam_handle_t *pamh, const char *name_value);
+	const char *(*pam_getenv)(pam_handle_t *pamh, const char *name);
+	char **(*pam_getenvlist)(pam_handle_t *pamh);
+};
+
+extern struct go_pam_dl go_pam_dl;
+
+/* Loads the library once, returning whether it's available. */
+int go_pam_dl_load(void);
+/* Returns why the library couldn't be loaded. */
+const char *go_pam_dl_error(void);
+/* Looks up a symbol of the library, returning NULL if it's not available. */
+void *go_pam_dl_sym(const char *name);
+
+static inline int pam_start(const char *service_name, const char *user,
+			    const struct pam_conv *pam_conversation, pam_handle_t **pamh)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_start(service_name, user, pam_conversation, pamh);
+}
+
+static inline int pam_end(pam_handle_t *pamh, int pam_status)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_end(pamh, pam_status);
+}
+
+static inline int pam_authenticate(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_authenticate(pamh, flags);
+}
+
+static inline int pam_setcred(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_setcred(pamh, flags);
+}
+
+static inline int pam_acct_mgmt(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_acct_mgmt(pamh, flags);
+}
+
+static inline int pam_open_session(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_open_session(pamh, flags);
+}
+
+static inline int pam_close_session(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_close_session(pamh, flags);
+}
+
+static inline int pam_chauthtok(pam_handle_t *pamh, int flags)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_chauthtok(pamh, flags);
+}
+
+static inline int pam_set_item(pam_handle_t *pamh, int item_type, const void *item)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_set_item(pamh, item_type, item);
+}
+
+static inline int pam_get_item(const pam_handle_t *pamh, int item_type, const void **item)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_get_item(pamh, item_type, item);
+}
+
+static inline const char *pam_strerror(pam_handle_t *pamh, int errnum)
+{
+	if (!go_pam_dl_load())
+		return "PAM library not available";
+	return go_pam_dl.pam_strerror(pamh, errnum);
+}
+
+static inline int pam_putenv(pam_handle_t *pamh, const char *name_value)
+{
+	if (!go_pam_dl_load())
+		return PAM_SYSTEM_ERR;
+	return go_pam_dl.pam_putenv(pamh, name_value);
+}
+
+static inline const char *pam_getenv(pam_handle_t *pamh, const char *name)
+{
+	if (!go_pam_dl_load())
+		return NULL;
+	return go_pam_dl.pam_getenv(pamh, name);
+}
+
+static inline char **pam_getenvlist(pam_handle_t *pamh)
+{
+	if (!go_pam_dl_load())
+		return NULL;
+	return go_pam_dl.pam_getenvlist(pamh);
+}
+
+#endif /* GO_PAM_DLOPEN_PAM_APPL_H */
@@ -5,9 +5,6 @@ package pam
 */
 import "C"
 
-// Error represents a PAM error.
-type Error int
-
 // Various errors returned by PAM.
 const (
 	// OpenErr indicates a dlopen() failure when dynamically loading a
@@ -78,8 +75,3 @@ const (
 	// ErrModuleUnknown indicates a module is not known.
 	ErrModuleUnknown Error = C.PAM_MODULE_UNKNOWN
 )
-
-// Error returns the error message for the given status.
-func (status Error) Error() string {
-	return C.GoString(C.pam_strerror(nil, C.int(status)))
-}
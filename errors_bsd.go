@@ -1,4 +1,4 @@
-//go:build freebsd
+//go:build freebsd || openpam
 
 package pam
 
@@ -11,4 +11,40 @@ import "C"
 const (
 	// ErrBadItem indicates a bad item passed to pam_*_item().
 	ErrBadItem Error = C.PAM_BAD_ITEM
+	// ErrBadHandle indicates an invalid PAM handle.
+	ErrBadHandle Error = C.PAM_BAD_HANDLE
+	// ErrBadFeature indicates an unrecognized or restricted feature.
+	ErrBadFeature Error = C.PAM_BAD_FEATURE
+	// ErrBadConstant indicates a bad constant passed to a PAM function.
+	ErrBadConstant Error = C.PAM_BAD_CONSTANT
+	// ErrDomainUnknown indicates an unknown authentication domain.
+	ErrDomainUnknown Error = C.PAM_DOMAIN_UNKNOWN
 )
+
+// platformErrors are the errors that are specific to this platform.
+var platformErrors = []Error{
+	ErrBadItem, ErrBadHandle, ErrBadFeature, ErrBadConstant, ErrDomainUnknown,
+}
+
+// platformErrorMessages are the messages of the errors that are specific to
+// this platform.
+var platformErrorMessages = map[Error]string{
+	ErrBadItem:       "Bad item passed to pam_*_item()",
+	ErrBadHandle:     "Invalid PAM handle",
+	ErrBadFeature:    "Unrecognized or restricted feature",
+	ErrBadConstant:   "Bad constant",
+	ErrDomainUnknown: "Unknown authentication domain",
+}
+
+// errBadItem is the error returned when an item can't be handled.
+const errBadItem = ErrBadItem
+
+// platformErrorNames are the names of the errors that are specific to this
+// platform.
+var platformErrorNames = map[Error]string{
+	ErrBadItem:       "PAM_BAD_ITEM",
+	ErrBadHandle:     "PAM_BAD_HANDLE",
+	ErrBadFeature:    "PAM_BAD_FEATURE",
+	ErrBadConstant:   "PAM_BAD_CONSTANT",
+	ErrDomainUnknown: "PAM_DOMAIN_UNKNOWN",
+}
@@ -1,4 +1,4 @@
-//go:build linux
+//go:build linux && !openpam
 
 package pam
 
@@ -19,3 +19,25 @@ const (
 	// is completed.
 	ErrIncomplete Error = C.PAM_INCOMPLETE
 )
+
+// platformErrors are the errors that are specific to this platform.
+var platformErrors = []Error{ErrBadItem, ErrConvAgain, ErrIncomplete}
+
+// platformErrorMessages are the messages of the errors that are specific to
+// this platform.
+var platformErrorMessages = map[Error]string{
+	ErrBadItem:    "Bad item passed to pam_*_item()",
+	ErrConvAgain:  "Conversation is waiting for event",
+	ErrIncomplete: "Application needs to call libpam again",
+}
+
+// errBadItem is the error returned when an item can't be handled.
+const errBadItem = ErrBadItem
+
+// platformErrorNames are the names of the errors that are specific to this
+// platform.
+var platformErrorNames = map[Error]string{
+	ErrBadItem:    "PAM_BAD_ITEM",
+	ErrConvAgain:  "PAM_CONV_AGAIN",
+	ErrIncomplete: "PAM_INCOMPLETE",
+}
@@ -0,0 +1,105 @@
+//go:build !cgo
+
+package pam
+
+// Various errors returned by PAM, with the values used by Linux-PAM.
+const (
+	// OpenErr indicates a dlopen() failure when dynamically loading a
+	// service module.
+	ErrOpen Error = 1
+	// ErrSymbol indicates a symbol not found.
+	ErrSymbol Error = 2
+	// ErrService indicates a error in service module.
+	ErrService Error = 3
+	// ErrSystem indicates a system error.
+	ErrSystem Error = 4
+	// ErrBuf indicates a memory buffer error.
+	ErrBuf Error = 5
+	// ErrPermDenied indicates a permission denied.
+	ErrPermDenied Error = 6
+	// ErrAuth indicates a authentication failure.
+	ErrAuth Error = 7
+	// ErrCredInsufficient indicates a can not access authentication data due to
+	// insufficient credentials.
+	ErrCredInsufficient Error = 8
+	// ErrAuthinfoUnavail indicates that the underlying authentication service
+	// can not retrieve authentication information.
+	ErrAuthinfoUnavail Error = 9
+	// ErrUserUnknown indicates a user not known to the underlying authentication
+	// module.
+	ErrUserUnknown Error = 10
+	// ErrMaxtries indicates that an authentication service has maintained a retry
+	// count which has been reached. No further retries should be attempted.
+	ErrMaxtries Error = 11
+	// ErrNewAuthtokReqd indicates a new authentication token required. This is
+	// normally returned if the machine security policies require that the
+	// password should be changed because the password is nil or it has aged.
+	ErrNewAuthtokReqd Error = 12
+	// ErrAcctExpired indicates that an user account has expired.
+	ErrAcctExpired Error = 13
+	// ErrSession indicates a can not make/remove an entry for the
+	// specified session.
+	ErrSession Error = 14
+	// ErrCredUnavail indicates that an underlying authentication service can not
+	// retrieve user credentials.
+	ErrCredUnavail Error = 15
+	// ErrCredExpired indicates that an user credentials expired.
+	ErrCredExpired Error = 16
+	// ErrCred indicates a failure setting user credentials.
+	ErrCred Error = 17
+	// ErrNoModuleData indicates a no module specific data is present.
+	ErrNoModuleData Error = 18
+	// ErrConv indicates a conversation error.
+	ErrConv Error = 19
+	// ErrAuthtokErr indicates an authentication token manipulation error.
+	ErrAuthtok Error = 20
+	// ErrAuthtokRecoveryErr indicates an authentication information cannot
+	// be recovered.
+	ErrAuthtokRecovery Error = 21
+	// ErrAuthtokLockBusy indicates am authentication token lock busy.
+	ErrAuthtokLockBusy Error = 22
+	// ErrAuthtokDisableAging indicates an authentication token aging disabled.
+	ErrAuthtokDisableAging Error = 23
+	// ErrTryAgain indicates a preliminary check by password service.
+	ErrTryAgain Error = 24
+	// ErrIgnore indicates to ignore underlying account module regardless of
+	// whether the control flag is required, optional, or sufficient.
+	ErrIgnore Error = 25
+	// ErrAbort indicates a critical error (module fail now request).
+	ErrAbort Error = 26
+	// ErrAuthtokExpired indicates an user's authentication token has expired.
+	ErrAuthtokExpired Error = 27
+	// ErrModuleUnknown indicates a module is not known.
+	ErrModuleUnknown Error = 28
+	// ErrBadItem indicates a bad item passed to pam_*_item().
+	ErrBadItem Error = 29
+	// ErrConvAgain indicates a conversation function is event driven and data
+	// is not available yet.
+	ErrConvAgain Error = 30
+	// ErrIncomplete indicates to please call this function again to complete
+	// authentication stack. Before calling again, verify that conversation
+	// is completed.
+	ErrIncomplete Error = 31
+)
+
+// platformErrors are the errors that are specific to this platform.
+var platformErrors = []Error{ErrBadItem, ErrConvAgain, ErrIncomplete}
+
+// errBadItem is the error returned when an item can't be handled.
+const errBadItem = ErrBadItem
+
+// platformErrorMessages are the messages of the errors that are specific to
+// this platform.
+var platformErrorMessages = map[Error]string{
+	ErrBadItem:    "Bad item passed to pam_*_item()",
+	ErrConvAgain:  "Conversation is waiting for event",
+	ErrIncomplete: "Application needs to call libpam again",
+}
+
+// platformErrorNames are the names of the errors that are specific to this
+// platform.
+var platformErrorNames = map[Error]string{
+	ErrBadItem:    "PAM_BAD_ITEM",
+	ErrConvAgain:  "PAM_CONV_AGAIN",
+	ErrIncomplete: "PAM_INCOMPLETE",
+}
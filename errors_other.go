@@ -0,0 +1,18 @@
+//go:build cgo && !linux && !freebsd && !openpam
+
+package pam
+
+// errBadItem is the error returned when an item can't be handled, as
+// PAM_BAD_ITEM is not available on this platform.
+const errBadItem = ErrSystem
+
+// platformErrors are the errors that are specific to this platform.
+var platformErrors []Error
+
+// platformErrorMessages are the messages of the errors that are specific to
+// this platform.
+var platformErrorMessages = map[Error]string{}
+
+// platformErrorNames are the names of the errors that are specific to this
+// platform.
+var platformErrorNames = map[Error]string{}
@@ -0,0 +1,40 @@
+package pam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorMessages(t *testing.T) {
+	t.Parallel()
+	for _, e := range Capabilities().Errors {
+		if msg := e.Error(); msg == "" || msg == "Unknown PAM error" {
+			t.Fatalf("error #missing message for %d", e)
+		}
+	}
+	if msg := Error(success).Error(); msg != "Success" {
+		t.Fatalf("error #unexpected message: %q", msg)
+	}
+	if msg := ErrAuth.Error(); msg != "Authentication failure" {
+		t.Fatalf("error #unexpected message: %q", msg)
+	}
+	if msg := Error(-1).Error(); msg != "Unknown PAM error" {
+		t.Fatalf("error #unexpected message: %q", msg)
+	}
+}
+
+func TestErrorNames(t *testing.T) {
+	t.Parallel()
+	for _, e := range Capabilities().Errors {
+		if name := e.Name(); !strings.HasPrefix(name, "PAM_") ||
+			strings.HasPrefix(name, "PAM_UNKNOWN_ERR") {
+			t.Fatalf("name #missing name for %d", e)
+		}
+	}
+	if name := ErrAuth.Name(); name != "PAM_AUTH_ERR" {
+		t.Fatalf("name #unexpected value: %q", name)
+	}
+	if name := Error(-1).Name(); name != "PAM_UNKNOWN_ERR(-1)" {
+		t.Fatalf("name #unexpected value: %q", name)
+	}
+}
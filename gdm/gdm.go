@@ -0,0 +1,58 @@
+// Package gdm implements the GDM PAM extensions, that allow modules and
+// greeters to exchange structured messages over PAM_BINARY_PROMPT.
+//
+// The extensions supported by the application are advertised to the modules
+// through the GDM_SUPPORTED_PAM_EXTENSIONS environment variable, and the
+// position of an extension in such list is the type of its messages.
+package gdm
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// SupportedExtensionsEnv is the environment variable listing the extensions
+// supported by the application.
+const SupportedExtensionsEnv = "GDM_SUPPORTED_PAM_EXTENSIONS"
+
+// CustomJSONExtension is the name of the extension used to exchange JSON
+// messages.
+const CustomJSONExtension = "org.gnome.DisplayManager.UserVerifier.CustomJSON"
+
+// AdvertiseExtensions sets the extensions supported by the application in
+// the process environment, where the modules look them up.
+func AdvertiseExtensions(names ...string) error {
+	if len(names) > math.MaxUint8+1 {
+		return fmt.Errorf("%w: too many extensions", pam.ErrSystem)
+	}
+	for _, name := range names {
+		if name == "" || strings.Contains(name, " ") {
+			return fmt.Errorf("%w: invalid extension name %q", pam.ErrSystem, name)
+		}
+	}
+	return os.Setenv(SupportedExtensionsEnv, strings.Join(names, " "))
+}
+
+// SupportedExtensions returns the extensions advertised in the process
+// environment.
+func SupportedExtensions() []string {
+	return strings.Fields(os.Getenv(SupportedExtensionsEnv))
+}
+
+// ExtensionType returns the type of the messages of the extension, if it's
+// advertised in the process environment.
+func ExtensionType(name string) (uint8, bool) {
+	for i, n := range SupportedExtensions() {
+		if i > math.MaxUint8 {
+			break
+		}
+		if n == name {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
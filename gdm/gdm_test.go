@@ -0,0 +1,109 @@
+//go:build cgo
+
+package gdm
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/msteinert/pam/v2"
+)
+
+func TestAdvertiseExtensions(t *testing.T) {
+	t.Setenv(SupportedExtensionsEnv, "")
+
+	if _, ok := ExtensionType(CustomJSONExtension); ok {
+		t.Fatalf("extension #unexpected type")
+	}
+	if err := AdvertiseExtensions("org.example.Other", CustomJSONExtension); err != nil {
+		t.Fatalf("advertise #error: %v", err)
+	}
+	if typ, ok := ExtensionType(CustomJSONExtension); !ok || typ != 1 {
+		t.Fatalf("extension #unexpected type: %v, %v", typ, ok)
+	}
+	if err := AdvertiseExtensions("invalid name"); !errors.Is(err, pam.ErrSystem) {
+		t.Fatalf("advertise #unexpected error: %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Setenv(SupportedExtensionsEnv, "")
+
+	protocols := &pam.BinaryProtocols{}
+	handler := JSONHandlerFunc(func(m JSONMessage) (json.RawMessage, error) {
+		if m.Protocol != "auth-selection" || m.Version != 2 {
+			t.Fatalf("handler #unexpected message: %#v", m)
+		}
+		var req struct {
+			Choices []string `json:"choices"`
+		}
+		if err := json.Unmarshal(m.JSON, &req); err != nil {
+			t.Fatalf("handler #error: %v", err)
+		}
+		return json.Marshal(map[string]string{"choice": req.Choices[1]})
+	})
+	if err := RegisterJSONHandler(protocols, handler); !errors.Is(err, pam.ErrSystem) {
+		t.Fatalf("register #unexpected error: %v", err)
+	}
+	if _, err := NewJSONRequest("auth-selection", 2, json.RawMessage(`{}`)); !errors.Is(err, pam.ErrConv) {
+		t.Fatalf("request #unexpected error: %v", err)
+	}
+
+	if err := AdvertiseExtensions(CustomJSONExtension); err != nil {
+		t.Fatalf("advertise #error: %v", err)
+	}
+	if err := RegisterJSONHandler(protocols, handler); err != nil {
+		t.Fatalf("register #error: %v", err)
+	}
+	c := pam.BinaryConversation{
+		ConversationHandler: pam.StaticCredentials{},
+		Protocols:           protocols,
+	}
+
+	req, err := NewJSONRequest("auth-selection", 2,
+		json.RawMessage(`{"choices": ["password", "smartcard"]}`))
+	if err != nil {
+		t.Fatalf("request #error: %v", err)
+	}
+	defer req.Free()
+
+	reply, err := c.RespondPAMBinary(req.Pointer())
+	if err != nil {
+		t.Fatalf("respond #error: %v", err)
+	}
+	m, err := DecodeJSONReply(pam.BinaryPointer(&reply[0]))
+	if err != nil {
+		t.Fatalf("reply #error: %v", err)
+	}
+	if m.Protocol != "auth-selection" || m.Version != 2 || string(m.JSON) != `{"choice":"smartcard"}` {
+		t.Fatalf("reply #unexpected message: %#v", m)
+	}
+
+	if _, err := NewJSONRequest("auth-selection", 2, json.RawMessage(`{`)); !errors.Is(err, pam.ErrConv) {
+		t.Fatalf("request #unexpected error: %v", err)
+	}
+}
+
+func TestJSON_InvalidReply(t *testing.T) {
+	t.Setenv(SupportedExtensionsEnv, "")
+
+	if err := AdvertiseExtensions(CustomJSONExtension); err != nil {
+		t.Fatalf("advertise #error: %v", err)
+	}
+	protocols := &pam.BinaryProtocols{}
+	err := RegisterJSONHandler(protocols, JSONHandlerFunc(func(m JSONMessage) (json.RawMessage, error) {
+		return json.RawMessage(`not json`), nil
+	}))
+	if err != nil {
+		t.Fatalf("register #error: %v", err)
+	}
+	req, err := NewJSONRequest("proto", 1, json.RawMessage(`null`))
+	if err != nil {
+		t.Fatalf("request #error: %v", err)
+	}
+	defer req.Free()
+	if _, err := protocols.RespondPAMBinary(req.Pointer()); !errors.Is(err, pam.ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
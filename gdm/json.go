@@ -0,0 +1,195 @@
+package gdm
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef struct {
+	uint32_t length;
+	unsigned char type;
+	unsigned char padding[3];
+} GdmPamExtensionMessage;
+
+typedef struct {
+	GdmPamExtensionMessage header;
+	const char *protocol_name;
+	unsigned int version;
+	char *json;
+} GdmPamExtensionJSONProtocol;
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"unsafe"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// JSONMessage is a message of the CustomJSON extension.
+type JSONMessage struct {
+	// Protocol is the name of the protocol of the payload.
+	Protocol string
+	// Version is the version of the protocol.
+	Version uint
+	// JSON is the payload.
+	JSON json.RawMessage
+}
+
+// JSONHandler is an interface for objects that can reply to the requests of
+// the CustomJSON extension.
+type JSONHandler interface {
+	// RespondGDMJSON receives a request sent by a module and returns the
+	// JSON payload of the reply.
+	RespondGDMJSON(JSONMessage) (json.RawMessage, error)
+}
+
+// JSONHandlerFunc is an adapter to allow the use of ordinary functions as
+// CustomJSON handlers.
+type JSONHandlerFunc func(JSONMessage) (json.RawMessage, error)
+
+// RespondGDMJSON is a CustomJSON handler adapter.
+func (f JSONHandlerFunc) RespondGDMJSON(m JSONMessage) (json.RawMessage, error) {
+	return f(m)
+}
+
+// RegisterJSONHandler registers the handler of the CustomJSON extension
+// messages in the protocols. The extension must have been advertised (see
+// AdvertiseExtensions).
+func RegisterJSONHandler(p *pam.BinaryProtocols, h JSONHandler) error {
+	t, ok := ExtensionType(CustomJSONExtension)
+	if !ok {
+		return fmt.Errorf("%w: %s extension is not advertised", pam.ErrSystem,
+			CustomJSONExtension)
+	}
+	p.Register(t, pam.BinaryMessageHandlerFunc(func(m pam.BinaryMessage) (pam.BinaryMessage, error) {
+		return respondJSON(m, h)
+	}))
+	return nil
+}
+
+func respondJSON(m pam.BinaryMessage, h JSONHandler) (pam.BinaryMessage, error) {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return pam.BinaryMessage{}, err
+	}
+	if len(b) < C.sizeof_GdmPamExtensionJSONProtocol {
+		return pam.BinaryMessage{}, fmt.Errorf("%w: truncated %s message",
+			pam.ErrConv, CustomJSONExtension)
+	}
+	// The data is a copy of the request, whose pointers are still owned by
+	// the module.
+	request := (*C.GdmPamExtensionJSONProtocol)(unsafe.Pointer(&b[0]))
+	req, err := decodeJSON(request)
+	if err != nil {
+		return pam.BinaryMessage{}, err
+	}
+
+	payload, err := h.RespondGDMJSON(req)
+	if err != nil {
+		return pam.BinaryMessage{}, err
+	}
+	if !json.Valid(payload) {
+		return pam.BinaryMessage{}, fmt.Errorf("%w: invalid JSON reply", pam.ErrConv)
+	}
+
+	reply := make([]byte, C.sizeof_GdmPamExtensionJSONProtocol)
+	r := (*C.GdmPamExtensionJSONProtocol)(unsafe.Pointer(&reply[0]))
+	r.protocol_name = request.protocol_name
+	r.version = request.version
+	// The module is responsible of releasing the reply payload.
+	r.json = C.CString(string(payload))
+	return pam.BinaryMessage{
+		Control: m.Control,
+		Data:    reply[pam.BinaryMessageHeaderSize:],
+	}, nil
+}
+
+func decodeJSON(m *C.GdmPamExtensionJSONProtocol) (JSONMessage, error) {
+	if m.protocol_name == nil || m.json == nil {
+		return JSONMessage{}, fmt.Errorf("%w: incomplete %s message",
+			pam.ErrConv, CustomJSONExtension)
+	}
+	payload := json.RawMessage(C.GoString(m.json))
+	if !json.Valid(payload) {
+		return JSONMessage{}, fmt.Errorf("%w: invalid JSON message", pam.ErrConv)
+	}
+	return JSONMessage{
+		Protocol: C.GoString(m.protocol_name),
+		Version:  uint(m.version),
+		JSON:     payload,
+	}, nil
+}
+
+// JSONRequest is a request of the CustomJSON extension, as sent by a module
+// through its conversation function.
+type JSONRequest struct {
+	msg *C.GdmPamExtensionJSONProtocol
+}
+
+// NewJSONRequest allocates a CustomJSON request, that must be released with
+// Free. The extension must be advertised by the application.
+func NewJSONRequest(protocol string, version uint, payload json.RawMessage) (*JSONRequest, error) {
+	t, ok := ExtensionType(CustomJSONExtension)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s extension is not supported", pam.ErrConv,
+			CustomJSONExtension)
+	}
+	if !json.Valid(payload) {
+		return nil, fmt.Errorf("%w: invalid JSON message", pam.ErrConv)
+	}
+	m := (*C.GdmPamExtensionJSONProtocol)(C.calloc(1, C.sizeof_GdmPamExtensionJSONProtocol))
+	if m == nil {
+		return nil, pam.ErrBuf
+	}
+	header := (*[pam.BinaryMessageHeaderSize]byte)(unsafe.Pointer(&m.header))
+	binary.BigEndian.PutUint32(header[:], C.sizeof_GdmPamExtensionJSONProtocol)
+	m.header._type = C.uchar(t)
+	m.protocol_name = C.CString(protocol)
+	m.version = C.uint(version)
+	m.json = C.CString(string(payload))
+	return &JSONRequest{m}, nil
+}
+
+// Pointer returns the request as the data of a PAM_BINARY_PROMPT message.
+func (r *JSONRequest) Pointer() pam.BinaryPointer {
+	return pam.BinaryPointer(r.msg)
+}
+
+// Free releases the request.
+func (r *JSONRequest) Free() {
+	if r.msg == nil {
+		return
+	}
+	C.free(unsafe.Pointer(r.msg.protocol_name))
+	C.free(unsafe.Pointer(r.msg.json))
+	C.free(unsafe.Pointer(r.msg))
+	r.msg = nil
+}
+
+// DecodeJSONReply decodes the reply to a CustomJSON request, as received by
+// the module, and releases the payload owned by it. The reply itself is
+// still owned by the caller.
+func DecodeJSONReply(p pam.BinaryPointer) (JSONMessage, error) {
+	m, err := pam.ReadBinaryMessage(p)
+	if err != nil {
+		return JSONMessage{}, err
+	}
+	t, ok := ExtensionType(CustomJSONExtension)
+	if !ok || m.Control != t {
+		return JSONMessage{}, fmt.Errorf("%w: unexpected reply type %d",
+			pam.ErrConv, m.Control)
+	}
+	if len(m.Data)+pam.BinaryMessageHeaderSize < C.sizeof_GdmPamExtensionJSONProtocol {
+		return JSONMessage{}, fmt.Errorf("%w: truncated %s reply",
+			pam.ErrConv, CustomJSONExtension)
+	}
+	reply := (*C.GdmPamExtensionJSONProtocol)(p)
+	defer func() {
+		C.free(unsafe.Pointer(reply.json))
+		reply.json = nil
+	}()
+	return decodeJSON(reply)
+}
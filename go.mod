@@ -1,7 +1,8 @@
 module github.com/msteinert/pam/v2
 
-go 1.20
+go 1.21
 
-require golang.org/x/term v0.6.0
-
-require golang.org/x/sys v0.6.0 // indirect
+require (
+	golang.org/x/sys v0.18.0
+	golang.org/x/term v0.18.0
+)
@@ -0,0 +1,205 @@
+//go:build cgo && linux && !openpam
+
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startGoTestModule starts a transaction on a service using the pam_gotest
+// module. The lines are the service contents, where %[1]s is the module
+// path.
+func startGoTestModule(t *testing.T, user string, handler ConversationHandler, lines ...string) *Transaction {
+	t.Helper()
+
+	if !CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	module := buildTestModule(t, "pam_gotest")
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf(line, module)
+	}
+	confDir := writeTestService(t, "gotest-service", lines...)
+
+	tx, err := StartConfDir("gotest-service", user, handler, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	t.Cleanup(func() { maybeEndTransaction(t, tx) })
+	ensureTransactionEnds(t, tx)
+	return tx
+}
+
+func TestGoTestModule_Errors(t *testing.T) {
+	t.Parallel()
+	statuses := map[string]error{
+		"new_authtok_reqd": ErrNewAuthtokReqd,
+		"acct_expired":     ErrAcctExpired,
+		"cred_expired":     ErrCredExpired,
+		"authtok_expired":  ErrAuthtokExpired,
+		"user_unknown":     ErrUserUnknown,
+		"maxtries":         ErrMaxtries,
+		"session_err":      ErrSession,
+		"bad_item":         ErrBadItem,
+	}
+	operations := map[string]func(*Transaction) error{
+		"auth":          func(tx *Transaction) error { return tx.Authenticate(0) },
+		"setcred":       func(tx *Transaction) error { return tx.SetCred(EstablishCred) },
+		"acct":          func(tx *Transaction) error { return tx.AcctMgmt(0) },
+		"open_session":  func(tx *Transaction) error { return tx.OpenSession(0) },
+		"close_session": func(tx *Transaction) error { return tx.CloseSession(0) },
+		"chauthtok":     func(tx *Transaction) error { return tx.ChangeAuthTok(0) },
+	}
+
+	for ret, expected := range statuses {
+		ret := ret
+		expected := expected
+		for name, operation := range operations {
+			name := name
+			operation := operation
+			t.Run(ret+" "+name, func(t *testing.T) {
+				t.Parallel()
+				tx := startGoTestModule(t, "testuser", StaticCredentials{},
+					"auth requisite %[1]s "+name+"="+ret,
+					"account requisite %[1]s "+name+"="+ret,
+					"password requisite %[1]s "+name+"="+ret,
+					"session requisite %[1]s "+name+"="+ret)
+				if err := operation(tx); !errors.Is(err, expected) {
+					t.Fatalf("%s #unexpected error: %v", name, err)
+				}
+			})
+		}
+	}
+}
+
+func TestGoTestModule_ExpiredPassword(t *testing.T) {
+	t.Parallel()
+	var messages []string
+	c := StaticCredentials{
+		Password:    "secret",
+		NewPassword: "newsecret",
+		OnMessage: func(s Style, msg string) {
+			messages = append(messages, msg)
+		},
+	}
+	tx := startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s authtok=secret",
+		"account requisite %[1]s acct=new_authtok_reqd",
+		"password requisite %[1]s oldauthtok=secret authtok=newsecret "+
+			moduleArg("info=Password changed"))
+
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, ErrNewAuthtokReqd) {
+		t.Fatalf("acct_mgmt #unexpected error: %v", err)
+	}
+	if err := tx.ChangeAuthTok(ChangeExpiredAuthtok); err != nil {
+		t.Fatalf("chauthtok #error: %v", err)
+	}
+	if len(messages) != 1 || messages[0] != "Password changed" {
+		t.Fatalf("chauthtok #unexpected messages: %v", messages)
+	}
+}
+
+func TestGoTestModule_WrongPassword(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{Password: "wrong", NewPassword: "newsecret"}
+	tx := startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s authtok=secret",
+		"password requisite %[1]s oldauthtok=secret authtok=newsecret")
+
+	if err := tx.Authenticate(0); !errors.Is(err, ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if err := tx.ChangeAuthTok(0); err == nil {
+		t.Fatalf("chauthtok #expected an error")
+	}
+}
+
+func TestGoTestModule_Prompts(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{User: "other", Password: "secret"}
+	tx := startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s "+moduleArg("echo_on=Your name: ")+" expect=other "+
+			moduleArg("echo_off=Your secret: ")+" expect=secret")
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+
+	c.Password = "wrong"
+	tx = startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s echo_off=Password: expect=secret")
+	if err := tx.Authenticate(0); !errors.Is(err, ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestGoTestModule_ItemsAndEnv(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"auth requisite %[1]s expect_item=tty:tty1 expect_item=ruser: "+
+			"item=rhost:example.com item=user:changed env=GOTEST_VAR=value")
+
+	if err := tx.SetItem(Tty, "tty1"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	items := map[Item]string{
+		Rhost: "example.com",
+		User:  "changed",
+		Tty:   "tty1",
+	}
+	for i, expected := range items {
+		if v, err := tx.GetItem(i); err != nil || v != expected {
+			t.Fatalf("getitem #unexpected value for %v: %q, %v", i, v, err)
+		}
+	}
+	if v := tx.GetEnv("GOTEST_VAR"); v != "value" {
+		t.Fatalf("getenv #unexpected value: %q", v)
+	}
+
+	if err := tx.SetItem(Tty, "tty2"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if err := tx.Authenticate(0); !errors.Is(err, ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
+
+func TestGoTestModule_Script(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"account requisite %[1]s acct=success")
+
+	script := filepath.Join(t.TempDir(), "script")
+	if err := tx.PutEnv("GOTEST_SCRIPT=" + script); err != nil {
+		t.Fatalf("putenv #error: %v", err)
+	}
+	steps := []struct {
+		script   []string
+		expected error
+	}{
+		{nil, nil},
+		{[]string{"acct=acct_expired"}, ErrAcctExpired},
+		{[]string{"echo_off=Password: ", "expect=secret"}, ErrConv},
+		{[]string{"acct=cred_insufficient"}, ErrCredInsufficient},
+		{[]string{"acct=success"}, nil},
+	}
+	for _, step := range steps {
+		contents := strings.Join(step.script, "\n")
+		if err := os.WriteFile(script, []byte(contents), 0600); err != nil {
+			t.Fatalf("can't write script: %v", err)
+		}
+		if err := tx.AcctMgmt(0); !errors.Is(err, step.expected) {
+			t.Fatalf("acct_mgmt #unexpected error with %v: %v", step.script, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package pam
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// OperationEvent describes an operation performed by a transaction. The same
+// event is passed to OnOperationStart and OnOperationEnd.
+type OperationEvent struct {
+	// Operation is the name of the PAM function, such as pam_authenticate.
+	Operation string
+	// Flags are the flags passed to the operation.
+	Flags Flags
+	// Service, User, Ruser, Rhost and Tty are the values of the items. User
+	// is updated when the operation ends, as modules may change it.
+	Service, User, Ruser, Rhost, Tty string
+	// Start is when the operation started.
+	Start time.Time
+	// Duration is how long the operation took, set when it ends.
+	Duration time.Duration
+	// Status is the result of the operation, set when it ends.
+	Status Error
+	// Err can be set by OnOperationEnd to make the operation return it in
+	// place of its result, such as to fail an operation that succeeded but
+	// couldn't be recorded.
+	Err error
+}
+
+// ConversationEvent describes a message that a module sent to the
+// conversation handler. The same event is passed to OnConversationStart and
+// OnConversationEnd.
+type ConversationEvent struct {
+	// Style is the style of the message.
+	Style Style
+	// Text is the message, it's empty for BinaryPrompt.
+	Text string
+	// Start is when the message was received.
+	Start time.Time
+	// Duration is how long the handler took to respond, set when it ends.
+	Duration time.Duration
+	// Status is the result of the conversation, set when it ends.
+	Status Error
+}
+
+// Hooks are notified of the operations performed by a transaction and of the
+// conversation messages that happen during them.
+//
+// The start hooks return the context that is passed to the matching end
+// hook, and from the operations to their conversations, so that they can be
+// used to carry spans.
+type Hooks interface {
+	OnOperationStart(ctx context.Context, e *OperationEvent) context.Context
+	OnOperationEnd(ctx context.Context, e *OperationEvent)
+	OnConversationStart(ctx context.Context, e *ConversationEvent) context.Context
+	OnConversationEnd(ctx context.Context, e *ConversationEvent)
+}
+
+// EndHooks are hooks that are also notified when the transaction ends.
+type EndHooks interface {
+	Hooks
+	OnEnd(ctx context.Context)
+}
+
+// AddHooks adds hooks to the transaction, which are invoked in the order
+// they're added. It reports whether the hooks were added, which they aren't
+// once the transaction has ended.
+func (t *Transaction) AddHooks(hooks ...Hooks) bool {
+	if t.callbacks == nil {
+		return false
+	}
+	t.callbacks.mu.Lock()
+	defer t.callbacks.mu.Unlock()
+	if t.callbacks.ended {
+		return false
+	}
+	// Copy the hooks, as the operations may be iterating over them.
+	t.callbacks.hooks = append(append([]Hooks(nil), t.callbacks.hooks...),
+		hooks...)
+	return true
+}
+
+// SetContext sets the context passed to the hooks when operations start, by
+// default it's context.Background().
+func (t *Transaction) SetContext(ctx context.Context) {
+	if t.callbacks == nil {
+		return
+	}
+	t.callbacks.mu.Lock()
+	defer t.callbacks.mu.Unlock()
+	t.callbacks.ctx = ctx
+}
+
+// activeHooks returns the hooks to invoke and the context to start with.
+func (cb *callbacks) activeHooks() (context.Context, []Hooks) {
+	if cb == nil {
+		return nil, nil
+	}
+	cb.mu.Lock()
+	ctx, hooks := cb.ctx, cb.hooks
+	cb.mu.Unlock()
+	if l := cb.logger.Load(); l != nil {
+		hooks = append([]Hooks{logHooks{l}}, hooks...)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx, hooks
+}
+
+// hooksRun tracks the invocation of the hooks for an operation or a
+// conversation.
+type hooksRun struct {
+	hooks []Hooks
+	ctxs  []context.Context
+}
+
+// ctx returns the context returned by the last hook.
+func (r *hooksRun) ctx() context.Context {
+	return r.ctxs[len(r.ctxs)-1]
+}
+
+// operationRun tracks the hooks of an operation in progress.
+type operationRun struct {
+	hooksRun
+	event *OperationEvent
+}
+
+// startOperation invokes the hooks when an operation starts, returning nil if
+// there are none.
+func (t *Transaction) startOperation(name string, f Flags) *operationRun {
+	ctx, hooks := t.callbacks.activeHooks()
+	if len(hooks) == 0 {
+		return nil
+	}
+	r := &operationRun{
+		hooksRun: hooksRun{hooks: hooks, ctxs: make([]context.Context, len(hooks))},
+		event: &OperationEvent{
+			Operation: name,
+			Flags:     f,
+			Service:   t.peekItem(Service),
+			User:      t.peekItem(User),
+			Ruser:     t.peekItem(Ruser),
+			Rhost:     t.peekItem(Rhost),
+			Tty:       t.peekItem(Tty),
+			Start:     time.Now(),
+		},
+	}
+	for i, h := range hooks {
+		ctx = h.OnOperationStart(ctx, r.event)
+		r.ctxs[i] = ctx
+	}
+	t.callbacks.current.Store(r)
+	return r
+}
+
+// endOperation invokes the hooks when an operation ends, returning the
+// error of the operation, unless a hook replaced it.
+func (t *Transaction) endOperation(r *operationRun, err error) error {
+	if r == nil {
+		return err
+	}
+	t.callbacks.current.Store(nil)
+	r.event.Duration = time.Since(r.event.Start)
+	r.event.User = t.peekItem(User)
+	r.event.Status = errorStatus(err)
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		r.hooks[i].OnOperationEnd(r.ctxs[i], r.event)
+	}
+	if r.event.Err != nil {
+		// pam_end must not be told that the operation succeeded.
+		t.lastStatus.Store(int32(errorStatus(r.event.Err)))
+		return r.event.Err
+	}
+	return err
+}
+
+// conversationRun tracks the hooks of a conversation in progress.
+type conversationRun struct {
+	hooksRun
+	event *ConversationEvent
+}
+
+// hasHooks reports whether there are hooks to invoke for a conversation.
+func (cb *callbacks) hasHooks() bool {
+	if cb.current.Load() != nil {
+		return true
+	}
+	_, hooks := cb.activeHooks()
+	return len(hooks) > 0
+}
+
+// startConversation invokes the hooks when a conversation message is
+// received, returning nil if there are none.
+func (cb *callbacks) startConversation(style Style, text string) *conversationRun {
+	var ctx context.Context
+	var hooks []Hooks
+	if op := cb.current.Load(); op != nil {
+		ctx, hooks = op.ctx(), op.hooks
+	} else {
+		ctx, hooks = cb.activeHooks()
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	r := &conversationRun{
+		hooksRun: hooksRun{hooks: hooks, ctxs: make([]context.Context, len(hooks))},
+		event:    &ConversationEvent{Style: style, Text: text, Start: time.Now()},
+	}
+	for i, h := range hooks {
+		ctx = h.OnConversationStart(ctx, r.event)
+		r.ctxs[i] = ctx
+	}
+	return r
+}
+
+// endConversation invokes the hooks when a conversation message has been
+// handled.
+func (cb *callbacks) endConversation(r *conversationRun, status Error) {
+	if r == nil {
+		return
+	}
+	r.event.Duration = time.Since(r.event.Start)
+	r.event.Status = status
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		r.hooks[i].OnConversationEnd(r.ctxs[i], r.event)
+	}
+}
+
+// endTransaction invokes the hooks when the transaction ends.
+func (cb *callbacks) endTransaction() {
+	cb.mu.Lock()
+	cb.ended = true
+	cb.mu.Unlock()
+	ctx, hooks := cb.activeHooks()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if h, ok := hooks[i].(EndHooks); ok {
+			h.OnEnd(ctx)
+		}
+	}
+}
+
+// errorStatus returns the PAM status matching err.
+func errorStatus(err error) Error {
+	status := Error(success)
+	if err != nil && !errors.As(err, &status) {
+		status = ErrSystem
+	}
+	return status
+}
@@ -0,0 +1,264 @@
+//go:build cgo && linux && !openpam
+
+package pam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type hooksKey struct{}
+
+type recordingHooks struct {
+	name   string
+	events *[]string
+}
+
+func (h recordingHooks) record(ctx context.Context, format string, args ...any) {
+	path, _ := ctx.Value(hooksKey{}).(string)
+	*h.events = append(*h.events, h.name+" "+fmt.Sprintf(format, args...)+" ["+path+"]")
+}
+
+func (h recordingHooks) OnOperationStart(ctx context.Context, e *OperationEvent) context.Context {
+	h.record(ctx, "start %s %s %s %s", e.Operation, e.Service, e.User, e.Rhost)
+	path, _ := ctx.Value(hooksKey{}).(string)
+	return context.WithValue(ctx, hooksKey{}, path+"/"+h.name)
+}
+
+func (h recordingHooks) OnOperationEnd(ctx context.Context, e *OperationEvent) {
+	if e.Duration <= 0 {
+		panic("missing duration")
+	}
+	h.record(ctx, "end %s %s %s", e.Operation, e.User, e.Status.Name())
+}
+
+func (h recordingHooks) OnConversationStart(ctx context.Context, e *ConversationEvent) context.Context {
+	h.record(ctx, "conv %s %q", e.Style, e.Text)
+	path, _ := ctx.Value(hooksKey{}).(string)
+	return context.WithValue(ctx, hooksKey{}, path+"/"+h.name+"-conv")
+}
+
+func (h recordingHooks) OnConversationEnd(ctx context.Context, e *ConversationEvent) {
+	h.record(ctx, "conv-end %s %s", e.Style, e.Status.Name())
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{Password: "secret"}
+	tx := startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s info=Hello echo_off=Password: expect=secret item=user:changed",
+		"account requisite %[1]s acct=acct_expired")
+
+	var events []string
+	tx.SetContext(context.WithValue(context.Background(), hooksKey{}, "root"))
+	tx.AddHooks(recordingHooks{"a", &events}, recordingHooks{"b", &events})
+	if err := tx.SetItem(Rhost, "example.com"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, ErrAcctExpired) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+
+	expected := []string{
+		`a start pam_authenticate gotest-service testuser example.com [root]`,
+		`b start pam_authenticate gotest-service testuser example.com [root/a]`,
+		`a conv TEXT_INFO "Hello" [root/a/b]`,
+		`b conv TEXT_INFO "Hello" [root/a/b/a-conv]`,
+		`b conv-end TEXT_INFO PAM_SUCCESS [root/a/b/a-conv/b-conv]`,
+		`a conv-end TEXT_INFO PAM_SUCCESS [root/a/b/a-conv]`,
+		`a conv PROMPT_ECHO_OFF "Password:" [root/a/b]`,
+		`b conv PROMPT_ECHO_OFF "Password:" [root/a/b/a-conv]`,
+		`b conv-end PROMPT_ECHO_OFF PAM_SUCCESS [root/a/b/a-conv/b-conv]`,
+		`a conv-end PROMPT_ECHO_OFF PAM_SUCCESS [root/a/b/a-conv]`,
+		`b end pam_authenticate changed PAM_SUCCESS [root/a/b]`,
+		`a end pam_authenticate changed PAM_SUCCESS [root/a]`,
+		`a start pam_acct_mgmt gotest-service changed example.com [root]`,
+		`b start pam_acct_mgmt gotest-service changed example.com [root/a]`,
+		`b end pam_acct_mgmt changed PAM_ACCT_EXPIRED [root/a/b]`,
+		`a end pam_acct_mgmt changed PAM_ACCT_EXPIRED [root/a]`,
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("hooks #unexpected events:\n%q\nexpected:\n%q", events, expected)
+	}
+}
+
+type endHooks struct {
+	recordingHooks
+}
+
+func (h endHooks) OnEnd(ctx context.Context) {
+	h.record(ctx, "end transaction")
+}
+
+type failingHooks struct {
+	recordingHooks
+}
+
+func (h failingHooks) OnOperationEnd(ctx context.Context, e *OperationEvent) {
+	if e.Status == 0 {
+		e.Err = fmt.Errorf("%w: failing on purpose", ErrSystem)
+	}
+}
+
+func TestHooks_Err(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"auth requisite %[1]s",
+		"account requisite %[1]s acct=acct_expired")
+
+	var events []string
+	tx.AddHooks(recordingHooks{"a", &events}, failingHooks{recordingHooks{"b", &events}})
+	if err := tx.Authenticate(0); !errors.Is(err, ErrSystem) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if status := Error(tx.lastStatus.Load()); status != ErrSystem {
+		t.Fatalf("authenticate #unexpected status: %v", status)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, ErrAcctExpired) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+	expected := []string{
+		`a start pam_authenticate gotest-service testuser  []`,
+		`b start pam_authenticate gotest-service testuser  [/a]`,
+		`a end pam_authenticate testuser PAM_SUCCESS [/a]`,
+		`a start pam_acct_mgmt gotest-service testuser  []`,
+		`b start pam_acct_mgmt gotest-service testuser  [/a]`,
+		`a end pam_acct_mgmt testuser PAM_ACCT_EXPIRED [/a]`,
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("hooks #unexpected events:\n%q\nexpected:\n%q", events, expected)
+	}
+}
+
+// conversationHooks records the conversation events.
+type conversationHooks struct {
+	recordingHooks
+	conversations *[]ConversationEvent
+}
+
+func (h conversationHooks) OnConversationEnd(ctx context.Context, e *ConversationEvent) {
+	h.recordingHooks.OnConversationEnd(ctx, e)
+	*h.conversations = append(*h.conversations, *e)
+}
+
+func TestHooks_Batch(t *testing.T) {
+	t.Parallel()
+	c := &batchConversation{
+		recordingConversation: recordingConversation{
+			StaticCredentials: StaticCredentials{User: "testuser", Password: "secret"},
+		},
+		delay: 10 * time.Millisecond,
+	}
+	conversationEvents := func(status string) []string {
+		return []string{
+			`a conv TEXT_INFO "An info message" [/a]`,
+			`a conv PROMPT_ECHO_ON "login: " [/a]`,
+			`a conv ERROR_MSG "An error message" [/a]`,
+			`a conv PROMPT_ECHO_OFF "Password: " [/a]`,
+			`a conv-end TEXT_INFO ` + status + ` [/a/a-conv]`,
+			`a conv-end PROMPT_ECHO_ON ` + status + ` [/a/a-conv]`,
+			`a conv-end ERROR_MSG ` + status + ` [/a/a-conv]`,
+			`a conv-end PROMPT_ECHO_OFF ` + status + ` [/a/a-conv]`,
+		}
+	}
+
+	for _, fail := range []bool{false, true} {
+		c.fail = fail
+		tx := startConversationTest(t, "conv-messages-service", c)
+		var events []string
+		var conversations []ConversationEvent
+		tx.AddHooks(conversationHooks{recordingHooks{"a", &events}, &conversations})
+		err := tx.Authenticate(0)
+		status := "PAM_SUCCESS"
+		if fail {
+			if !errors.Is(err, ErrConv) {
+				t.Fatalf("authenticate #unexpected error: %v", err)
+			}
+			status = "PAM_CONV_ERR"
+		} else if err != nil {
+			t.Fatalf("authenticate #error: %v", err)
+		}
+
+		expected := append([]string{
+			`a start pam_authenticate conv-messages-service testuser  []`,
+		}, conversationEvents(status)...)
+		expected = append(expected, `a end pam_authenticate testuser `+status+` [/a]`)
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("hooks #unexpected events:\n%q\nexpected:\n%q", events, expected)
+		}
+		for _, e := range conversations {
+			if e.Duration < c.delay {
+				t.Fatalf("hooks #unexpected conversation duration: %v", e.Duration)
+			}
+		}
+	}
+}
+
+func TestHooks_End(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"auth requisite %[1]s")
+
+	var events []string
+	if !tx.AddHooks(recordingHooks{"a", &events}, endHooks{recordingHooks{"b", &events}}) {
+		t.Fatalf("add hooks #unexpected failure")
+	}
+	if err := tx.End(); err != nil {
+		t.Fatalf("end #error: %v", err)
+	}
+	if err := tx.End(); err != nil {
+		t.Fatalf("end #error: %v", err)
+	}
+	if tx.AddHooks(endHooks{recordingHooks{"c", &events}}) {
+		t.Fatalf("add hooks #unexpected success after end")
+	}
+	expected := []string{`b end transaction []`}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("hooks #unexpected events:\n%q\nexpected:\n%q", events, expected)
+	}
+}
+
+func TestHooks_ConversationError(t *testing.T) {
+	t.Parallel()
+	handler := ConversationFunc(func(s Style, msg string) (string, error) {
+		return "", errors.New("no answer")
+	})
+	tx := startGoTestModule(t, "testuser", handler,
+		"auth requisite %[1]s echo_on=Name:")
+
+	var events []string
+	tx.AddHooks(recordingHooks{"a", &events})
+	if err := tx.Authenticate(0); !errors.Is(err, ErrConv) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	expected := []string{
+		`a start pam_authenticate gotest-service testuser  []`,
+		`a conv PROMPT_ECHO_ON "Name:" [/a]`,
+		`a conv-end PROMPT_ECHO_ON PAM_CONV_ERR [/a/a-conv]`,
+		`a end pam_authenticate testuser PAM_CONV_ERR [/a]`,
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("hooks #unexpected events:\n%q\nexpected:\n%q", events, expected)
+	}
+}
+
+func TestFailure_Hooks(t *testing.T) {
+	t.Parallel()
+	var events []string
+	tx := Transaction{}
+	tx.SetContext(context.Background())
+	tx.AddHooks(recordingHooks{"a", &events})
+	if err := tx.Authenticate(0); err == nil {
+		t.Fatalf("authenticate #expected an error")
+	}
+	if len(events) != 0 {
+		t.Fatalf("hooks #unexpected events: %v", events)
+	}
+}
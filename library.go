@@ -0,0 +1,25 @@
+//go:build !pam_dlopen
+
+package pam
+
+/*
+#include <dlfcn.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import "unsafe"
+
+// loadLibrary ensures that the PAM library is available. It is linked to
+// the program, so it always is.
+func loadLibrary() error {
+	return nil
+}
+
+// lookupSymbol returns the address of a symbol provided by the PAM library,
+// or nil if it's not available.
+func lookupSymbol(name string) unsafe.Pointer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.dlsym(C.RTLD_NEXT, cname)
+}
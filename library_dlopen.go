@@ -0,0 +1,32 @@
+//go:build pam_dlopen
+
+package pam
+
+/*
+#include <security/pam_appl.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// loadLibrary ensures that the PAM library is available, loading it the
+// first time.
+func loadLibrary() error {
+	if C.go_pam_dl_load() == 0 {
+		return fmt.Errorf("%w: %s", ErrLibraryNotLoaded,
+			C.GoString(C.go_pam_dl_error()))
+	}
+	return nil
+}
+
+// lookupSymbol returns the address of a symbol provided by the PAM library,
+// or nil if it's not available.
+func lookupSymbol(name string) unsafe.Pointer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return C.go_pam_dl_sym(cname)
+}
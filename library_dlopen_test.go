@@ -0,0 +1,18 @@
+//go:build cgo && pam_dlopen
+
+package pam
+
+import "testing"
+
+func TestLoadLibrary(t *testing.T) {
+	t.Parallel()
+	if err := loadLibrary(); err != nil {
+		t.Fatalf("loadlibrary #error: %v", err)
+	}
+	if lookupSymbol("pam_start") == nil {
+		t.Fatalf("lookupsymbol #expected pam_start")
+	}
+	if lookupSymbol("pam_does_not_exist") != nil {
+		t.Fatalf("lookupsymbol #unexpected symbol")
+	}
+}
@@ -0,0 +1,63 @@
+package pam
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetLogger sets the logger used to report what the transaction does.
+//
+// Each operation is logged at the info level, or at the warning level if it
+// fails, with the service, user, remote host, flags, duration and resulting
+// error name. Conversation messages are logged at the debug level with their
+// style and, except for PromptEchoOff and BinaryPrompt, their text. The
+// responses are never logged. A nil logger disables logging.
+func (t *Transaction) SetLogger(l *slog.Logger) {
+	if t.callbacks == nil {
+		return
+	}
+	t.callbacks.logger.Store(l)
+}
+
+// logHooks are the hooks logging what the transaction does.
+type logHooks struct {
+	l *slog.Logger
+}
+
+func (h logHooks) OnOperationStart(ctx context.Context, e *OperationEvent) context.Context {
+	return ctx
+}
+
+func (h logHooks) OnOperationEnd(ctx context.Context, e *OperationEvent) {
+	level := slog.LevelInfo
+	if e.Status != success {
+		level = slog.LevelWarn
+	}
+	h.l.LogAttrs(ctx, level, "pam operation",
+		slog.String("operation", e.Operation),
+		slog.String("service", e.Service),
+		slog.String("user", e.User),
+		slog.String("rhost", e.Rhost),
+		slog.Int("flags", int(e.Flags)),
+		slog.Duration("duration", e.Duration),
+		slog.String("result", e.Status.Name()))
+}
+
+func (h logHooks) OnConversationStart(ctx context.Context, e *ConversationEvent) context.Context {
+	return ctx
+}
+
+func (h logHooks) OnConversationEnd(ctx context.Context, e *ConversationEvent) {
+	if !h.l.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("style", e.Style.String())}
+	if e.Style != PromptEchoOff && e.Style != BinaryPrompt {
+		attrs = append(attrs, slog.String("text", e.Text))
+	}
+	if e.Style == PromptEchoOff || e.Style == PromptEchoOn {
+		attrs = append(attrs, slog.String("response", "<redacted>"))
+	}
+	attrs = append(attrs, slog.String("result", e.Status.Name()))
+	h.l.LogAttrs(ctx, slog.LevelDebug, "pam conversation", attrs...)
+}
@@ -0,0 +1,99 @@
+//go:build cgo && linux && !openpam
+
+package pam
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{User: "other", Password: "topsecret"}
+	tx := startGoTestModule(t, "testuser", c,
+		"auth requisite %[1]s info=Welcome "+moduleArg("echo_on=Your name: ")+
+			" expect=other "+moduleArg("echo_off=Your secret: ")+" expect=topsecret",
+		"account requisite %[1]s acct=acct_expired")
+
+	var buf bytes.Buffer
+	tx.SetLogger(slog.New(slog.NewJSONHandler(&buf,
+		&slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := tx.SetItem(Rhost, "example.com"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if err := tx.Authenticate(Silent); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, ErrAcctExpired) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "topsecret") {
+		t.Fatalf("log #leaked the password: %s", buf.String())
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("log #error: %v", err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 5 {
+		t.Fatalf("log #unexpected records: %v", records)
+	}
+
+	expected := []map[string]any{
+		{"level": "DEBUG", "style": "TEXT_INFO", "text": "Welcome", "result": "PAM_SUCCESS"},
+		{"level": "DEBUG", "style": "PROMPT_ECHO_ON", "text": "Your name: ",
+			"response": "<redacted>", "result": "PAM_SUCCESS"},
+		{"level": "DEBUG", "style": "PROMPT_ECHO_OFF", "response": "<redacted>",
+			"result": "PAM_SUCCESS"},
+		{"level": "INFO", "operation": "pam_authenticate", "service": "gotest-service",
+			"user": "testuser", "rhost": "example.com", "flags": float64(Silent),
+			"result": "PAM_SUCCESS"},
+		{"level": "WARN", "operation": "pam_acct_mgmt", "service": "gotest-service",
+			"user": "testuser", "rhost": "example.com", "flags": float64(0),
+			"result": "PAM_ACCT_EXPIRED"},
+	}
+	for i, r := range records {
+		for k, v := range expected[i] {
+			if r[k] != v {
+				t.Fatalf("log #unexpected %s in record %d: %v", k, i, r)
+			}
+		}
+	}
+	if _, ok := records[2]["text"]; ok {
+		t.Fatalf("log #unexpected text: %v", records[2])
+	}
+	if _, ok := records[3]["duration"]; !ok {
+		t.Fatalf("log #missing duration: %v", records[3])
+	}
+
+	buf.Reset()
+	tx.SetLogger(nil)
+	if err := tx.AcctMgmt(0); !errors.Is(err, ErrAcctExpired) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("log #unexpected output: %s", buf.String())
+	}
+}
+
+func TestFailure_Logger(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	tx := Transaction{}
+	tx.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	if err := tx.Authenticate(0); err == nil {
+		t.Fatalf("authenticate #expected an error")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("log #unexpected output: %s", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+// Package pam provides a wrapper for the PAM application API.
+package pam
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLibraryNotLoaded is returned when the PAM library can't be loaded at
+// runtime, which only happens when building with the pam_dlopen tag.
+var ErrLibraryNotLoaded = errors.New("pam: library not loaded")
+
+// ErrUnsupported is returned by all the operations when the package is built
+// without cgo, so that PAM can't be used.
+var ErrUnsupported = errors.New("pam: not supported without cgo")
+
+// Error represents a PAM error.
+type Error int
+
+// Item is a an PAM information type.
+type Item int
+
+// checkItem verifies that the item can be handled as a string by the
+// application.
+func checkItem(i Item) error {
+	if nonStringItems[i] {
+		return fmt.Errorf("%w: item %d is not a string", errBadItem, i)
+	}
+	return nil
+}
+
+// checkSetItem verifies that the item can be set by the application.
+func checkSetItem(i Item) error {
+	if err := checkItem(i); err != nil {
+		return err
+	}
+	if moduleItems[i] {
+		return fmt.Errorf("%w: item %d can only be set by modules", errBadItem, i)
+	}
+	return nil
+}
+
+// Flags are inputs to various PAM functions than be combined with a bitwise
+// or. Refer to the official PAM documentation for which flags are accepted
+// by which functions.
+type Flags int
@@ -0,0 +1,136 @@
+// Package pamaudit records the decisions taken by PAM transactions as
+// structured events, which are sent to sinks such as a hash chained JSON
+// lines file or syslog.
+package pamaudit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// Event is an audit record of a PAM operation.
+type Event struct {
+	// Time is when the operation ended.
+	Time time.Time `json:"time"`
+	// Operation is the name of the PAM function, such as pam_authenticate.
+	Operation string `json:"operation"`
+	Service   string `json:"service"`
+	User      string `json:"user"`
+	Ruser     string `json:"ruser,omitempty"`
+	Rhost     string `json:"rhost,omitempty"`
+	Tty       string `json:"tty,omitempty"`
+	// Result is the name of the PAM status, such as PAM_SUCCESS.
+	Result string `json:"result"`
+	// Success is whether the operation succeeded.
+	Success bool `json:"success"`
+}
+
+// Sink receives the audit events. It must be safe for concurrent use.
+type Sink interface {
+	WriteEvent(e Event) error
+}
+
+// Option configures an Auditor.
+type Option func(*Auditor)
+
+// WithOperations sets the operations that are audited, by default all of
+// them are.
+func WithOperations(operations ...string) Option {
+	return func(a *Auditor) {
+		a.operations = map[string]bool{}
+		for _, op := range operations {
+			a.operations[op] = true
+		}
+	}
+}
+
+// WithErrorHandler sets the function called when a sink fails to write an
+// event. By default the errors are logged with the default slog logger.
+func WithErrorHandler(f func(error)) Option {
+	return func(a *Auditor) {
+		a.onError = f
+	}
+}
+
+// WithFailClosed makes the operations that succeeded fail with pam.ErrSystem
+// when their event can't be written to a sink, so that nothing is allowed
+// without being audited. By default the sink errors are only passed to the
+// error handler, and don't affect the operations.
+func WithFailClosed() Option {
+	return func(a *Auditor) {
+		a.failClosed = true
+	}
+}
+
+// Auditor is the hooks sending an event to the sinks for each operation of
+// the transactions it's added to (see pam.Transaction.AddHooks).
+type Auditor struct {
+	sinks      []Sink
+	operations map[string]bool
+	onError    func(error)
+	failClosed bool
+	now        func() time.Time
+}
+
+// New returns an auditor writing to the sinks.
+func New(sinks []Sink, opts ...Option) *Auditor {
+	a := &Auditor{
+		sinks: sinks,
+		onError: func(err error) {
+			slog.Error("pam audit", slog.String("error", err.Error()))
+		},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// OnOperationStart implements pam.Hooks.
+func (a *Auditor) OnOperationStart(ctx context.Context, e *pam.OperationEvent) context.Context {
+	return ctx
+}
+
+// OnOperationEnd implements pam.Hooks, writing the event to the sinks. The
+// errors of the sinks are passed to the error handler and, with
+// WithFailClosed, make the operation fail.
+func (a *Auditor) OnOperationEnd(ctx context.Context, e *pam.OperationEvent) {
+	if a.operations != nil && !a.operations[e.Operation] {
+		return
+	}
+	event := Event{
+		Time:      a.now().UTC(),
+		Operation: e.Operation,
+		Service:   e.Service,
+		User:      e.User,
+		Ruser:     e.Ruser,
+		Rhost:     e.Rhost,
+		Tty:       e.Tty,
+		Result:    e.Status.Name(),
+		Success:   e.Status == 0,
+	}
+	for _, s := range a.sinks {
+		if err := s.WriteEvent(event); err != nil {
+			a.onError(err)
+			if a.failClosed && event.Success && e.Err == nil {
+				e.Err = fmt.Errorf("%w: audit: %w", pam.ErrSystem, err)
+			}
+		}
+	}
+}
+
+// OnConversationStart implements pam.Hooks.
+func (a *Auditor) OnConversationStart(ctx context.Context, e *pam.ConversationEvent) context.Context {
+	return ctx
+}
+
+// OnConversationEnd implements pam.Hooks.
+func (a *Auditor) OnConversationEnd(ctx context.Context, e *pam.ConversationEvent) {
+}
+
+var _ pam.Hooks = (*Auditor)(nil)
@@ -0,0 +1,103 @@
+package pamaudit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+type failingSink struct{}
+
+func (failingSink) WriteEvent(e Event) error { return errors.New("disk full") }
+
+func TestAuditor(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	contents := "auth required pam_permit.so\n" +
+		"account required pam_deny.so\n" +
+		"session required pam_permit.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "audit-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+
+	var m Memory
+	var errs []error
+	a := New([]Sink{&m, failingSink{}},
+		WithOperations("pam_authenticate", "pam_acct_mgmt"),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	tx, err := pam.StartConfDir("audit-service", "testuser", pam.StaticCredentials{}, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	defer tx.End()
+	tx.AddHooks(a)
+	for i, v := range map[pam.Item]string{pam.Rhost: "example.com", pam.Tty: "pts/1"} {
+		if err := tx.SetItem(i, v); err != nil {
+			t.Fatalf("setitem #error: %v", err)
+		}
+	}
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+	if err := tx.OpenSession(0); err != nil {
+		t.Fatalf("opensession #error: %v", err)
+	}
+
+	base := Event{Time: now, Service: "audit-service", User: "testuser",
+		Rhost: "example.com", Tty: "pts/1"}
+	auth, acct := base, base
+	auth.Operation, auth.Result, auth.Success = "pam_authenticate", "PAM_SUCCESS", true
+	acct.Operation, acct.Result = "pam_acct_mgmt", "PAM_AUTH_ERR"
+	if events := m.Events(); !reflect.DeepEqual(events, []Event{auth, acct}) {
+		t.Fatalf("audit #unexpected events: %+v", events)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("audit #unexpected errors: %v", errs)
+	}
+}
+
+func TestAuditor_FailClosed(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	contents := "auth required pam_permit.so\n" +
+		"account required pam_deny.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "audit-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+
+	var errs []error
+	a := New([]Sink{failingSink{}}, WithFailClosed(),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	tx, err := pam.StartConfDir("audit-service", "testuser", pam.StaticCredentials{}, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	defer tx.End()
+	tx.AddHooks(a)
+	if err := tx.Authenticate(0); !errors.Is(err, pam.ErrSystem) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("audit #unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,182 @@
+package pamaudit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrChainBroken is returned when the hash chain of a JSON lines log doesn't
+// match its contents.
+var ErrChainBroken = errors.New("audit log hash chain is broken")
+
+// Memory is a sink keeping the events in memory, mostly useful in tests.
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// WriteEvent implements Sink.
+func (m *Memory) WriteEvent(e Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+// Events returns the events written so far.
+func (m *Memory) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event(nil), m.events...)
+}
+
+// record is a line of a JSON lines log. Hash is the SHA-256, or the
+// HMAC-SHA-256 if there is a key, of the record encoded without it, which
+// includes the hash of the previous record, so that modifying, inserting or
+// removing records breaks the chain.
+type record struct {
+	Event
+	Prev string `json:"prev"`
+	Hash string `json:"hash,omitempty"`
+}
+
+func (r *record) sum(key []byte) (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+	data, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// JSONLinesOption configures a JSONLines sink.
+type JSONLinesOption func(*JSONLines)
+
+// WithKey sets the key used to hash the records with HMAC-SHA-256 rather
+// than SHA-256. Without a key, whoever can write to the log can also
+// recompute the chain after modifying it.
+func WithKey(key []byte) JSONLinesOption {
+	return func(j *JSONLines) {
+		j.key = append([]byte(nil), key...)
+	}
+}
+
+// JSONLines is a sink writing each event as a JSON object on its own line,
+// chained to the previous ones by their hashes.
+type JSONLines struct {
+	mu   sync.Mutex
+	w    io.Writer
+	key  []byte
+	prev string
+}
+
+// NewJSONLines returns a sink starting a new chain on w.
+func NewJSONLines(w io.Writer, opts ...JSONLinesOption) *JSONLines {
+	j := &JSONLines{w: w}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// OpenJSONLines opens the log at path, creating it if needed, and returns a
+// sink appending to it. The existing records are verified first, and the
+// new ones are chained to the last of them.
+func OpenJSONLines(path string, opts ...JSONLinesOption) (*JSONLines, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	j := NewJSONLines(f, opts...)
+	if j.prev, err = Verify(f, j.key); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return j, nil
+}
+
+// Head returns the hash of the last record, or an empty string if there are
+// none. Storing it outside of the log, such as on a remote host, allows to
+// detect records removed from its end, and without a key, a chain that was
+// recomputed.
+func (j *JSONLines) Head() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.prev
+}
+
+// WriteEvent implements Sink.
+func (j *JSONLines) WriteEvent(e Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := record{Event: e, Prev: j.prev}
+	hash, err := r.sum(j.key)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := j.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	j.prev = hash
+	return nil
+}
+
+// Close closes the underlying writer, if it's an io.Closer.
+func (j *JSONLines) Close() error {
+	if c, ok := j.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Verify checks the hash chain of a JSON lines log written with key, which is
+// nil if the log was written without one. It returns ErrChainBroken if any
+// record doesn't match the chain, and otherwise the hash of the last record.
+//
+// The log alone only proves that the records are consistent with each other.
+// Removing records from its end is detected by comparing the hash returned
+// with a Head saved elsewhere, and without a key, so is a log modified and
+// hashed again.
+func Verify(r io.Reader, key []byte) (string, error) {
+	var prev string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if rec.Prev != prev {
+			return "", fmt.Errorf("line %d: %w", line, ErrChainBroken)
+		}
+		hash, err := rec.sum(key)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if hash != rec.Hash {
+			return "", fmt.Errorf("line %d: %w", line, ErrChainBroken)
+		}
+		prev = hash
+	}
+	return prev, scanner.Err()
+}
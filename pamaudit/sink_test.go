@@ -0,0 +1,119 @@
+package pamaudit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvents() []Event {
+	t := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []Event{
+		{Time: t, Operation: "pam_authenticate", Service: "login", User: "alice",
+			Rhost: "example.com", Result: "PAM_SUCCESS", Success: true},
+		{Time: t.Add(time.Second), Operation: "pam_authenticate", Service: "login",
+			User: "bob", Tty: "tty1", Result: "PAM_AUTH_ERR"},
+		{Time: t.Add(2 * time.Second), Operation: "pam_acct_mgmt", Service: "login",
+			User: "alice", Result: "PAM_SUCCESS", Success: true},
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var b bytes.Buffer
+	j := NewJSONLines(&b)
+	for _, e := range testEvents() {
+		if err := j.WriteEvent(e); err != nil {
+			t.Fatalf("write #error: %v", err)
+		}
+	}
+	log := b.String()
+	head, err := Verify(strings.NewReader(log), nil)
+	if err != nil {
+		t.Fatalf("verify #error: %v", err)
+	}
+	if head == "" || head != j.Head() {
+		t.Fatalf("verify #unexpected head: %q, %q", head, j.Head())
+	}
+
+	lines := strings.SplitAfter(log, "\n")
+	tampered := map[string]string{
+		"modified":  strings.Replace(log, `"user":"bob"`, `"user":"eve"`, 1),
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+	}
+	for name, contents := range tampered {
+		if _, err := Verify(strings.NewReader(contents), nil); !errors.Is(err, ErrChainBroken) {
+			t.Fatalf("verify #unexpected error for %s log: %v", name, err)
+		}
+	}
+}
+
+func TestJSONLines_Key(t *testing.T) {
+	key := []byte("secret key")
+	write := func(key []byte, events []Event) string {
+		var b bytes.Buffer
+		j := NewJSONLines(&b, WithKey(key))
+		for _, e := range events {
+			if err := j.WriteEvent(e); err != nil {
+				t.Fatalf("write #error: %v", err)
+			}
+		}
+		return b.String()
+	}
+	events := testEvents()
+	log := write(key, events)
+	if _, err := Verify(strings.NewReader(log), key); err != nil {
+		t.Fatalf("verify #error: %v", err)
+	}
+	if _, err := Verify(strings.NewReader(log), nil); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("verify #unexpected error without key: %v", err)
+	}
+
+	// The chain can't be recomputed without the key.
+	events[1].User = "eve"
+	for _, forgery := range []string{write(nil, events), write([]byte("guess"), events)} {
+		if _, err := Verify(strings.NewReader(forgery), key); !errors.Is(err, ErrChainBroken) {
+			t.Fatalf("verify #unexpected error for forged log: %v", err)
+		}
+	}
+}
+
+func TestOpenJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	events := testEvents()
+	for _, e := range events {
+		j, err := OpenJSONLines(path)
+		if err != nil {
+			t.Fatalf("open #error: %v", err)
+		}
+		if err := j.WriteEvent(e); err != nil {
+			t.Fatalf("write #error: %v", err)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatalf("close #error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read #error: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != len(events) {
+		t.Fatalf("open #unexpected number of records: %d", n)
+	}
+	if _, err := Verify(bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("verify #error: %v", err)
+	}
+
+	data = bytes.Replace(data, []byte(`"user":"bob"`), []byte(`"user":"eve"`), 1)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	if _, err := OpenJSONLines(path); !errors.Is(err, ErrChainBroken) {
+		t.Fatalf("open #unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+
+package pamaudit
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// Syslog is a sink sending the events as JSON messages to syslog, using the
+// authpriv facility. Successful operations are logged with the notice
+// severity, failed ones with the warning severity.
+type Syslog struct {
+	w *syslog.Writer
+}
+
+// DialSyslog connects to the syslog daemon at raddr, using network, or to
+// the local one if network is empty (see syslog.Dial).
+func DialSyslog(network, raddr, tag string) (*Syslog, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_AUTHPRIV|syslog.LOG_NOTICE, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Syslog{w: w}, nil
+}
+
+// WriteEvent implements Sink.
+func (s *Syslog) WriteEvent(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.Success {
+		return s.w.Notice(string(data))
+	}
+	return s.w.Warning(string(data))
+}
+
+// Close closes the connection to syslog.
+func (s *Syslog) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,43 @@
+//go:build !windows && !plan9
+
+package pamaudit
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyslog(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("can't listen on unix socket: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := DialSyslog("unixgram", addr, "gotest")
+	if err != nil {
+		t.Fatalf("dial #error: %v", err)
+	}
+	defer s.Close()
+
+	// The priority is the facility (authpriv is 10) * 8 + the severity.
+	expected := []string{"<85>", "<84>"}
+	for i, e := range testEvents()[:2] {
+		if err := s.WriteEvent(e); err != nil {
+			t.Fatalf("write #error: %v", err)
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read #error: %v", err)
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, expected[i]) || !strings.Contains(msg, "gotest") ||
+			!strings.Contains(msg, `"user":"`+e.User+`"`) {
+			t.Fatalf("syslog #unexpected message: %q", msg)
+		}
+	}
+}
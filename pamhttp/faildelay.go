@@ -0,0 +1,18 @@
+//go:build !cgo || (linux && !openpam)
+
+package pamhttp
+
+import (
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// deferFailDelay makes PAM store in delay the delay requested by the modules
+// after a failure instead of sleeping, so that it can be waited for once the
+// transaction doesn't hold a concurrency slot anymore.
+func deferFailDelay(tx *pam.Transaction, delay *time.Duration) error {
+	return tx.SetFailDelayFunc(func(_ pam.Error, d time.Duration) {
+		*delay = d
+	})
+}
@@ -0,0 +1,15 @@
+//go:build cgo && (!linux || openpam)
+
+package pamhttp
+
+import (
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// deferFailDelay does nothing, as this platform doesn't support overriding
+// the delays requested by the modules: PAM sleeps during the transaction.
+func deferFailDelay(tx *pam.Transaction, delay *time.Duration) error {
+	return nil
+}
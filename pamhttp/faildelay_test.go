@@ -0,0 +1,36 @@
+//go:build !cgo || (linux && !openpam)
+
+package pamhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+func TestAuthenticate_FailDelay(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := writeService(t,
+		"auth optional pam_faildelay.so delay=2000000\n"+
+			"auth requisite pam_deny.so\n")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	start := time.Now()
+	_, delay, err := authenticate("http-service",
+		&options{confDir: confDir}, "testuser", "secret", r)
+	if !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("authenticate #waited for the fail delay: %v", elapsed)
+	}
+	if delay <= 0 {
+		t.Fatalf("authenticate #unexpected delay: %v", delay)
+	}
+}
@@ -0,0 +1,212 @@
+// Package pamhttp provides a net/http middleware that authenticates requests
+// with HTTP Basic authentication against a PAM service.
+package pamhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// DefaultMaxConcurrent is the default number of PAM transactions that the
+// middleware runs at the same time.
+const DefaultMaxConcurrent = 8
+
+// Option configures the middleware.
+type Option func(*options)
+
+type options struct {
+	realm         string
+	confDir       string
+	flags         pam.Flags
+	maxConcurrent int
+}
+
+// WithRealm sets the realm advertised in the WWW-Authenticate header.
+func WithRealm(realm string) Option {
+	return func(o *options) {
+		o.realm = realm
+	}
+}
+
+// WithConfDir makes the middleware look up the PAM service in confDir
+// instead of the system PAM configuration (see pam.StartConfDir).
+func WithConfDir(confDir string) Option {
+	return func(o *options) {
+		o.confDir = confDir
+	}
+}
+
+// WithFlags sets the flags passed to Authenticate and AcctMgmt.
+func WithFlags(f pam.Flags) Option {
+	return func(o *options) {
+		o.flags = f
+	}
+}
+
+// WithMaxConcurrent limits the number of PAM transactions running at the
+// same time. Requests exceeding the limit wait for a free slot.
+func WithMaxConcurrent(n int) Option {
+	return func(o *options) {
+		o.maxConcurrent = n
+	}
+}
+
+type contextKey struct{}
+
+// User returns the user authenticated by the middleware.
+func User(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(contextKey{}).(string)
+	return user, ok
+}
+
+// BasicAuth returns a middleware that authenticates each request against
+// the PAM service, using the credentials of the Authorization header.
+//
+// Authenticate and AcctMgmt are run in a new transaction for every request
+// and, on success, the authenticated user is stored in the request context
+// (see User). Requests that can't be authenticated are rejected with
+// 401 Unauthorized. Where PAM supports it, the delay requested by the modules
+// after a failure is waited for once the transaction has ended, so that it
+// doesn't hold one of the concurrent transaction slots.
+func BasicAuth(service string, opts ...Option) func(http.Handler) http.Handler {
+	o := options{
+		realm:         "Restricted",
+		flags:         pam.Silent,
+		maxConcurrent: DefaultMaxConcurrent,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxConcurrent <= 0 {
+		o.maxConcurrent = 1
+	}
+	slots := make(chan struct{}, o.maxConcurrent)
+	challenge := `Basic realm=` + quoteString(o.realm) + `, charset="UTF-8"`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			if !ok || user == "" {
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-r.Context().Done():
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			user, delay, err := authenticate(service, &o, user, password, r)
+			<-slots
+			if delay > 0 {
+				// Wait for the fail delay without holding a slot.
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-r.Context().Done():
+					timer.Stop()
+				}
+			}
+
+			var startErr *startError
+			switch {
+			case errors.As(err, &startErr):
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			case err != nil:
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), contextKey{}, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticate runs the PAM transaction and returns the user name as
+// known by PAM, as modules may have changed it, and the delay that the
+// modules requested after a failure, where it can be deferred.
+func authenticate(service string, o *options, user, password string, r *http.Request) (string, time.Duration, error) {
+	c := pam.StaticCredentials{User: user, Password: password}
+
+	var tx *pam.Transaction
+	var err error
+	if o.confDir == "" {
+		tx, err = pam.Start(service, user, c)
+	} else {
+		tx, err = pam.StartConfDir(service, user, c, o.confDir)
+	}
+	if err != nil {
+		return "", 0, &startError{err}
+	}
+	defer func() { _ = tx.End() }()
+
+	var delay time.Duration
+	if err := deferFailDelay(tx, &delay); err != nil {
+		return "", 0, err
+	}
+	if host := remoteHost(r.RemoteAddr); host != "" {
+		if err := tx.SetItem(pam.Rhost, host); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := tx.Authenticate(o.flags); err != nil {
+		return "", delay, err
+	}
+	if err := tx.AcctMgmt(o.flags); err != nil {
+		return "", delay, err
+	}
+	user, err = tx.GetItem(pam.User)
+	return user, 0, err
+}
+
+// startError reports a failure to start the transaction, that is a
+// configuration issue rather than an authentication failure.
+type startError struct {
+	err error
+}
+
+func (e *startError) Error() string {
+	return e.err.Error()
+}
+
+func (e *startError) Unwrap() error {
+	return e.err
+}
+
+// quoteString returns s as an RFC 7230 quoted-string, escaping the quotes
+// and backslashes and dropping the control characters that it can't hold.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\t' || (c >= ' ' && c != 0x7f):
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,113 @@
+package pamhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/msteinert/pam/v2"
+)
+
+func writeService(t *testing.T, contents string) string {
+	t.Helper()
+
+	confDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(confDir, "http-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+	return confDir
+}
+
+func serve(t *testing.T, confDir string, setAuth bool, opts ...Option) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	var user string
+	h := BasicAuth("http-service", append(opts, WithConfDir(confDir))...)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var ok bool
+			user, ok = User(r.Context())
+			if !ok {
+				t.Fatalf("handler #no user in context")
+			}
+		}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if setAuth {
+		r.SetBasicAuth("testuser", "secret")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w, user
+}
+
+func TestBasicAuth(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := writeService(t,
+		"auth requisite pam_succeed_if.so user = testuser rhost = 192.0.2.1\n"+
+			"account required pam_permit.so\n")
+
+	w, user := serve(t, confDir, true, WithMaxConcurrent(1))
+	if w.Code != http.StatusOK {
+		t.Fatalf("serve #unexpected status: %v", w.Code)
+	}
+	if user != "testuser" {
+		t.Fatalf("serve #unexpected user: %v", user)
+	}
+}
+
+func TestBasicAuth_NoCredentials(t *testing.T) {
+	w, _ := serve(t, t.TempDir(), false, WithRealm("dashboard"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("serve #unexpected status: %v", w.Code)
+	}
+	if h := w.Header().Get("WWW-Authenticate"); h != `Basic realm="dashboard", charset="UTF-8"` {
+		t.Fatalf("serve #unexpected challenge: %v", h)
+	}
+}
+
+func TestBasicAuth_Deny(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := writeService(t,
+		"auth required pam_permit.so\n"+
+			"account requisite pam_deny.so\n")
+
+	w, _ := serve(t, confDir, true)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("serve #unexpected status: %v", w.Code)
+	}
+	if h := w.Header().Get("WWW-Authenticate"); h == "" {
+		t.Fatalf("serve #expected a challenge")
+	}
+}
+
+func TestBasicAuth_NoService(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	w, _ := serve(t, t.TempDir(), true)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("serve #unexpected status: %v", w.Code)
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	t.Parallel()
+	strings := map[string]string{
+		"dashboard":          `"dashboard"`,
+		`say "hi"`:           `"say \"hi\""`,
+		`C:\realm`:           `"C:\\realm"`,
+		"tab\tnew\nline\x7f": "\"tab\tnewline\"",
+		"übersicht":          `"übersicht"`,
+	}
+	for s, expected := range strings {
+		if q := quoteString(s); q != expected {
+			t.Fatalf("quote #unexpected result for %q: %s", s, q)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !cgo || (linux && !openpam)
+
+package pamlimit
+
+import (
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// overrideFailDelay makes PAM skip the delays requested by the modules after
+// a failure, returning a function restoring the previous fail delay function.
+func overrideFailDelay(tx *pam.Transaction) (func(), error) {
+	previous := tx.FailDelayFunc()
+	if err := tx.SetFailDelayFunc(func(pam.Error, time.Duration) {}); err != nil {
+		return nil, err
+	}
+	return func() { _ = tx.SetFailDelayFunc(previous) }, nil
+}
@@ -0,0 +1,11 @@
+//go:build cgo && (!linux || openpam)
+
+package pamlimit
+
+import "github.com/msteinert/pam/v2"
+
+// overrideFailDelay does nothing, as this platform doesn't support
+// overriding the delays requested by the modules.
+func overrideFailDelay(tx *pam.Transaction) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,49 @@
+//go:build !cgo || (linux && !openpam)
+
+package pamlimit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+func TestLimiter_AuthenticateFailDelayFunc(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	contents := "auth optional pam_faildelay.so delay=2000000\n" +
+		"auth requisite pam_deny.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "deny-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+	tx, err := pam.StartConfDir("deny-service", "testuser", pam.StaticCredentials{}, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	defer tx.End()
+	var calls int
+	if err := tx.SetFailDelayFunc(func(pam.Error, time.Duration) { calls++ }); err != nil {
+		t.Fatalf("setfaildelayfunc #error: %v", err)
+	}
+
+	l, _ := newTestLimiter()
+	if err := l.Authenticate(tx, 0); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("faildelay #unexpected calls: %d", calls)
+	}
+	if err := tx.Authenticate(0); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("faildelay #function not restored: %d calls", calls)
+	}
+}
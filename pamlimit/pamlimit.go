@@ -0,0 +1,309 @@
+// Package pamlimit protects PAM authentication against brute force attacks,
+// delaying and then refusing the attempts of a user from a remote host after
+// consecutive failures.
+package pamlimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// Default values of the limiter options.
+const (
+	DefaultBaseDelay        = time.Second
+	DefaultMaxDelay         = time.Minute
+	DefaultLockoutThreshold = 5
+	DefaultLockoutDuration  = 15 * time.Minute
+)
+
+var (
+	// ErrLimited is the error matched by a *LimitError.
+	ErrLimited = errors.New("pamlimit: too many authentication failures")
+	// ErrNoUser is returned when the user of the transaction isn't set, as
+	// its attempts couldn't be limited.
+	ErrNoUser = errors.New("pamlimit: the user isn't set")
+)
+
+// LimitError is returned when an attempt is refused without invoking PAM.
+// It matches both ErrLimited and pam.ErrMaxtries.
+type LimitError struct {
+	// Locked is whether the user is locked out, rather than only delayed.
+	Locked bool
+	// RetryAfter is how long to wait before the next attempt.
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("%v: locked out for %v", ErrLimited, e.RetryAfter)
+	}
+	return fmt.Sprintf("%v: retry after %v", ErrLimited, e.RetryAfter)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimited || target == pam.ErrMaxtries
+}
+
+// Option configures a Limiter.
+type Option func(*Limiter)
+
+// WithBackoff sets the delay required after the first failure, which is
+// doubled after each consecutive failure up to max.
+func WithBackoff(base, max time.Duration) Option {
+	return func(l *Limiter) {
+		l.baseDelay = base
+		l.maxDelay = max
+	}
+}
+
+// WithLockout sets the number of consecutive failures after which the user
+// is locked out, and for how long. A threshold of 0 disables the lockouts.
+//
+// The failures are forgotten when the duration passes without new ones.
+func WithLockout(threshold int, duration time.Duration) Option {
+	return func(l *Limiter) {
+		l.lockoutThreshold = threshold
+		l.lockoutDuration = duration
+	}
+}
+
+type key struct {
+	user, rhost string
+}
+
+type entry struct {
+	failures int
+	// attempts is the number of attempts in progress.
+	attempts int
+	// last is when the last failure happened.
+	last time.Time
+	// next is when the next attempt is allowed.
+	next time.Time
+}
+
+// Limiter tracks the consecutive authentication failures by user and remote
+// host. It's safe for concurrent use.
+type Limiter struct {
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	lockoutThreshold int
+	lockoutDuration  time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	entries   map[key]*entry
+	lastSweep time.Time
+}
+
+// New returns a new limiter.
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		baseDelay:        DefaultBaseDelay,
+		maxDelay:         DefaultMaxDelay,
+		lockoutThreshold: DefaultLockoutThreshold,
+		lockoutDuration:  DefaultLockoutDuration,
+		now:              time.Now,
+		entries:          map[key]*entry{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Authenticate calls tx.Authenticate, unless the user has failed to
+// authenticate from the same remote host too recently, in which case it
+// returns a *LimitError without invoking PAM.
+//
+// The user and remote host are the values of the User and Rhost items. The
+// user must be set, such as when starting the transaction, otherwise
+// ErrNoUser is returned: the modules would prompt for it, and the limits
+// couldn't be checked before. Failures are results of pam.ErrAuth or
+// pam.ErrUserUnknown, and a success resets them. The attempts in progress
+// count as failures for the concurrent ones. The delays that modules request
+// after a failure are skipped where the platform allows it, as the limiter
+// applies its own: the function set with SetFailDelayFunc, if any, isn't
+// called during the authentication, and is restored afterwards.
+func (l *Limiter) Authenticate(tx *pam.Transaction, f pam.Flags) error {
+	user, err := tx.GetItem(pam.User)
+	if err != nil {
+		return err
+	}
+	if user == "" {
+		return ErrNoUser
+	}
+	rhost, err := tx.GetItem(pam.Rhost)
+	if err != nil {
+		return err
+	}
+	k := key{user, rhost}
+	if err := l.check(k); err != nil {
+		return err
+	}
+
+	restore, err := overrideFailDelay(tx)
+	if err != nil {
+		l.release(k)
+		return err
+	}
+	defer restore()
+
+	authErr := tx.Authenticate(f)
+	switch {
+	case authErr == nil:
+		l.succeed(k)
+	case errors.Is(authErr, pam.ErrAuth), errors.Is(authErr, pam.ErrUserUnknown):
+		l.fail(k)
+	default:
+		l.release(k)
+	}
+	return authErr
+}
+
+// Reset forgets the failures of the user from the remote host, unlocking it.
+func (l *Limiter) Reset(user, rhost string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := key{user, rhost}
+	if e := l.entries[k]; e != nil {
+		l.reset(k, e)
+	}
+}
+
+// reset forgets the failures of an entry.
+func (l *Limiter) reset(k key, e *entry) {
+	if e.attempts == 0 {
+		delete(l.entries, k)
+		return
+	}
+	*e = entry{attempts: e.attempts}
+}
+
+// check reserves an attempt, or returns an error if it isn't allowed yet.
+//
+// The attempts in progress are counted as failures, so that concurrent
+// attempts can't exceed the lockout threshold, and only one attempt at a time
+// is allowed after a failure.
+func (l *Limiter) check(k key) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	e := l.entries[k]
+	if e == nil || l.expired(e, now) {
+		e = &entry{}
+		l.entries[k] = e
+	}
+	if wait := e.next.Sub(now); wait > 0 {
+		return &LimitError{
+			Locked:     l.locked(e.failures),
+			RetryAfter: wait,
+		}
+	}
+	if e.attempts > 0 && (e.failures > 0 || l.locked(e.failures+e.attempts)) {
+		return &LimitError{RetryAfter: l.delay(e.failures + e.attempts)}
+	}
+	e.attempts++
+	return nil
+}
+
+// release ends an attempt that neither failed nor succeeded.
+func (l *Limiter) release(k key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.entries[k]
+	if e == nil {
+		return
+	}
+	if e.attempts > 0 {
+		e.attempts--
+	}
+	if e.attempts == 0 && e.failures == 0 {
+		delete(l.entries, k)
+	}
+}
+
+// succeed ends a successful attempt, resetting the failures.
+func (l *Limiter) succeed(k key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.entries[k]
+	if e == nil {
+		return
+	}
+	if e.attempts > 0 {
+		e.attempts--
+	}
+	l.reset(k, e)
+}
+
+// fail ends an attempt that failed, recording the failure.
+func (l *Limiter) fail(k key) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	e := l.entries[k]
+	if e == nil || l.expired(e, now) {
+		e = &entry{}
+		l.entries[k] = e
+	}
+	if e.attempts > 0 {
+		e.attempts--
+	}
+	e.failures++
+	e.last = now
+	if l.locked(e.failures) {
+		e.next = now.Add(l.lockoutDuration)
+		return
+	}
+	e.next = now.Add(l.delay(e.failures))
+}
+
+// locked reports whether the number of failures locks the user out.
+func (l *Limiter) locked(failures int) bool {
+	return l.lockoutThreshold > 0 && failures >= l.lockoutThreshold
+}
+
+// delay returns the delay required after the number of failures.
+func (l *Limiter) delay(failures int) time.Duration {
+	delay := l.baseDelay
+	for i := 1; i < failures && delay < l.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.maxDelay {
+		delay = l.maxDelay
+	}
+	return delay
+}
+
+// expired reports whether the failures of the entry are to be forgotten,
+// which they aren't while attempts are in progress.
+func (l *Limiter) expired(e *entry, now time.Time) bool {
+	return e.attempts == 0 && !now.Before(e.next) &&
+		now.Sub(e.last) >= l.forgetAfter()
+}
+
+func (l *Limiter) forgetAfter() time.Duration {
+	if l.lockoutDuration > l.maxDelay {
+		return l.lockoutDuration
+	}
+	return l.maxDelay
+}
+
+// sweep removes the expired entries, at most once per forget interval so
+// that memory doesn't grow with the number of users ever seen.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.forgetAfter() {
+		return
+	}
+	l.lastSweep = now
+	for k, e := range l.entries {
+		if l.expired(e, now) {
+			delete(l.entries, k)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package pamlimit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/msteinert/pam/v2"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestLimiter(opts ...Option) (*Limiter, *clock) {
+	c := &clock{time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(opts...)
+	l.now = c.now
+	return l, c
+}
+
+func checkLimit(t *testing.T, err error, locked bool, retryAfter time.Duration) {
+	t.Helper()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimited) ||
+		!errors.Is(err, pam.ErrMaxtries) {
+		t.Fatalf("check #unexpected error: %v", err)
+	}
+	if limitErr.Locked != locked || limitErr.RetryAfter != retryAfter {
+		t.Fatalf("check #unexpected limit: %+v", limitErr)
+	}
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	l, c := newTestLimiter(WithBackoff(time.Second, 5*time.Second),
+		WithLockout(6, time.Hour))
+	k := key{"user", "example.com"}
+	other := key{"user", "example.org"}
+
+	for _, delay := range []time.Duration{1, 2, 4, 5, 5} {
+		l.fail(k)
+		checkLimit(t, l.check(k), false, delay*time.Second)
+		if err := l.check(other); err != nil {
+			t.Fatalf("check #unexpected error for other host: %v", err)
+		}
+		l.release(other)
+		c.t = c.t.Add(delay * time.Second)
+		if err := l.check(k); err != nil {
+			t.Fatalf("check #error: %v", err)
+		}
+	}
+
+	l.fail(k)
+	checkLimit(t, l.check(k), true, time.Hour)
+	c.t = c.t.Add(time.Hour)
+	if err := l.check(k); err != nil {
+		t.Fatalf("check #error: %v", err)
+	}
+	l.succeed(k)
+	if len(l.entries) != 0 {
+		t.Fatalf("check #unexpected entries: %v", l.entries)
+	}
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	l, c := newTestLimiter(WithBackoff(time.Second, time.Minute),
+		WithLockout(3, time.Hour))
+	k := key{"user", "example.com"}
+	checkConcurrently := func() int {
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if l.check(k) == nil {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		return int(allowed.Load())
+	}
+
+	// The attempts in progress can't exceed the lockout threshold.
+	if n := checkConcurrently(); n != 3 {
+		t.Fatalf("check #unexpected allowed attempts: %d", n)
+	}
+	l.fail(k)
+	l.release(k)
+	l.release(k)
+
+	// After a failure, only one attempt at a time is allowed.
+	c.t = c.t.Add(time.Second)
+	if n := checkConcurrently(); n != 1 {
+		t.Fatalf("check #unexpected allowed attempts: %d", n)
+	}
+	l.fail(k)
+	checkLimit(t, l.check(k), false, 2*time.Second)
+
+	// An attempt ending without a result doesn't reset the failures.
+	c.t = c.t.Add(2 * time.Second)
+	if err := l.check(k); err != nil {
+		t.Fatalf("check #error: %v", err)
+	}
+	l.release(k)
+	if err := l.check(k); err != nil {
+		t.Fatalf("check #error: %v", err)
+	}
+	l.fail(k)
+	checkLimit(t, l.check(k), true, time.Hour)
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	l, _ := newTestLimiter(WithLockout(1, time.Hour))
+	k := key{"user", ""}
+	l.fail(k)
+	checkLimit(t, l.check(k), true, time.Hour)
+	l.Reset("user", "")
+	if err := l.check(k); err != nil {
+		t.Fatalf("check #error: %v", err)
+	}
+
+	// Resetting while an attempt is in progress keeps it counted.
+	l.Reset("user", "")
+	if e := l.entries[k]; e == nil || e.attempts != 1 {
+		t.Fatalf("reset #unexpected entry: %+v", e)
+	}
+	l.release(k)
+	if len(l.entries) != 0 {
+		t.Fatalf("reset #unexpected entries: %v", l.entries)
+	}
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	l, c := newTestLimiter(WithLockout(0, 0))
+	l.fail(key{"a", ""})
+	c.t = c.t.Add(DefaultMaxDelay)
+	l.fail(key{"b", ""})
+	if _, ok := l.entries[key{"a", ""}]; ok || len(l.entries) != 1 {
+		t.Fatalf("sweep #unexpected entries: %v", l.entries)
+	}
+}
+
+func TestLimiter_Authenticate(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	services := map[string]string{
+		"deny-service": "auth optional pam_faildelay.so delay=2000000\n" +
+			"auth requisite pam_deny.so\n",
+		"permit-service": "auth required pam_permit.so\n",
+	}
+	for name, contents := range services {
+		if err := os.WriteFile(filepath.Join(confDir, name),
+			[]byte(contents), 0600); err != nil {
+			t.Fatalf("can't create service file: %v", err)
+		}
+	}
+	authenticate := func(l *Limiter, service, user string) error {
+		tx, err := pam.StartConfDir(service, user, pam.StaticCredentials{}, confDir)
+		if err != nil {
+			t.Fatalf("start #error: %v", err)
+		}
+		defer tx.End()
+		if err := tx.SetItem(pam.Rhost, "example.com"); err != nil {
+			t.Fatalf("setitem #error: %v", err)
+		}
+		return l.Authenticate(tx, 0)
+	}
+
+	l, c := newTestLimiter(WithLockout(2, time.Minute))
+	start := time.Now()
+	if err := authenticate(l, "deny-service", "testuser"); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("authenticate #unexpected delay: %v", elapsed)
+	}
+	checkLimit(t, authenticate(l, "permit-service", "testuser"), false, time.Second)
+
+	c.t = c.t.Add(time.Second)
+	if err := authenticate(l, "deny-service", "testuser"); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	checkLimit(t, authenticate(l, "permit-service", "testuser"), true, time.Minute)
+
+	c.t = c.t.Add(time.Minute)
+	if err := authenticate(l, "permit-service", "testuser"); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if len(l.entries) != 0 {
+		t.Fatalf("authenticate #unexpected entries: %v", l.entries)
+	}
+
+	if err := authenticate(l, "permit-service", ""); !errors.Is(err, ErrNoUser) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+}
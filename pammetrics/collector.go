@@ -0,0 +1,163 @@
+package pammetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a metric.
+type Type int
+
+const (
+	// CounterType is a value that only increases.
+	CounterType Type = iota
+	// GaugeType is a value that can go up and down.
+	GaugeType
+	// HistogramType counts observations in buckets.
+	HistogramType
+)
+
+// String returns the name of the type in the Prometheus text format.
+func (t Type) String() string {
+	switch t {
+	case CounterType:
+		return "counter"
+	case GaugeType:
+		return "gauge"
+	case HistogramType:
+		return "histogram"
+	}
+	return "untyped"
+}
+
+// Label is a name and value pair identifying a sample.
+type Label struct {
+	Name, Value string
+}
+
+// Bucket is a histogram bucket, counting the observations less than or
+// equal to its upper bound.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Sample is a value of a metric.
+type Sample struct {
+	Labels []Label
+	// Value is the value of counters and gauges, or the sum of the
+	// observations of histograms.
+	Value float64
+	// Count is the number of observations of histograms.
+	Count uint64
+	// Buckets are the cumulative buckets of histograms.
+	Buckets []Bucket
+}
+
+// Family is a metric with all its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector is implemented by the sources of metrics.
+type Collector interface {
+	Collect() []Family
+}
+
+// WriteText writes the metrics of the collectors in the Prometheus text
+// exposition format.
+func WriteText(w io.Writer, collectors ...Collector) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			writeFamily(bw, f)
+		}
+	}
+	return bw.Flush()
+}
+
+func writeFamily(w *bufio.Writer, f Family) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Samples {
+		if f.Type != HistogramType {
+			writeSample(w, f.Name, s.Labels, formatFloat(s.Value))
+			continue
+		}
+		for _, b := range s.Buckets {
+			writeSample(w, f.Name+"_bucket",
+				append(s.Labels[:len(s.Labels):len(s.Labels)],
+					Label{"le", formatFloat(b.UpperBound)}),
+				strconv.FormatUint(b.Count, 10))
+		}
+		writeSample(w, f.Name+"_bucket",
+			append(s.Labels[:len(s.Labels):len(s.Labels)], Label{"le", "+Inf"}),
+			strconv.FormatUint(s.Count, 10))
+		writeSample(w, f.Name+"_sum", s.Labels, formatFloat(s.Value))
+		writeSample(w, f.Name+"_count", s.Labels, strconv.FormatUint(s.Count, 10))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels []Label, value string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l.Name, escapeLabel(l.Value))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(value)
+	w.WriteByte('\n')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortSamples sorts the samples by their label values, so that the output is
+// stable.
+func sortSamples(samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].Labels, samples[j].Labels
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k].Value != b[k].Value {
+				return a[k].Value < b[k].Value
+			}
+		}
+		return len(a) < len(b)
+	})
+}
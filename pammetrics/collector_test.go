@@ -0,0 +1,74 @@
+package pammetrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+type staticCollector []Family
+
+func (c staticCollector) Collect() []Family { return c }
+
+func TestWriteText(t *testing.T) {
+	c := staticCollector{{
+		Name: "test_total",
+		Help: "A counter\nwith \\ escapes.",
+		Type: CounterType,
+		Samples: []Sample{
+			{Labels: []Label{{"a", `quoted "value"`}, {"b", "line\nbreak"}}, Value: 3},
+		},
+	}, {
+		Name:    "test_gauge",
+		Help:    "A gauge.",
+		Type:    GaugeType,
+		Samples: []Sample{{Value: math.Inf(-1)}, {Value: 0.25}},
+	}, {
+		Name: "test_seconds",
+		Help: "A histogram.",
+		Type: HistogramType,
+		Samples: []Sample{{
+			Labels:  []Label{{"op", "x"}},
+			Value:   1.5,
+			Count:   3,
+			Buckets: []Bucket{{0.5, 1}, {1, 2}},
+		}},
+	}}
+
+	var b bytes.Buffer
+	if err := WriteText(&b, c); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	expected := `# HELP test_total A counter\nwith \\ escapes.
+# TYPE test_total counter
+test_total{a="quoted \"value\"",b="line\nbreak"} 3
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge -Inf
+test_gauge 0.25
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{op="x",le="0.5"} 1
+test_seconds_bucket{op="x",le="1"} 2
+test_seconds_bucket{op="x",le="+Inf"} 3
+test_seconds_sum{op="x"} 1.5
+test_seconds_count{op="x"} 3
+`
+	if b.String() != expected {
+		t.Fatalf("write #unexpected output:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 2})
+	for _, v := range []float64{0.5, 1, 1.5, 3} {
+		h.observe(v)
+	}
+	s := h.sample(Label{"a", "b"})
+	if s.Count != 4 || s.Value != 6 {
+		t.Fatalf("histogram #unexpected count or sum: %v %v", s.Count, s.Value)
+	}
+	if s.Buckets[0].Count != 2 || s.Buckets[1].Count != 3 {
+		t.Fatalf("histogram #unexpected buckets: %v", s.Buckets)
+	}
+}
@@ -0,0 +1,254 @@
+// Package pammetrics collects metrics about PAM transactions: the outcomes of
+// the operations by service and error, how long the operations and the
+// conversations take, and how many transactions are open.
+//
+// The metrics are exposed through the Collector interface, so that they can
+// be adapted to any monitoring system, and can be written in the Prometheus
+// text exposition format with WriteText.
+package pammetrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/msteinert/pam/v2"
+)
+
+// Names of the metrics.
+const (
+	OperationsTotal      = "pam_operations_total"
+	OperationDuration    = "pam_operation_duration_seconds"
+	ConversationDuration = "pam_conversation_duration_seconds"
+	OpenTransactions     = "pam_open_transactions"
+)
+
+var (
+	// DefaultOperationBuckets are the default histogram buckets of the
+	// operation durations, in seconds.
+	DefaultOperationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DefaultConversationBuckets are the default histogram buckets of the
+	// conversation durations, in seconds. They are larger than the ones of
+	// the operations, as prompts usually wait for a user.
+	DefaultConversationBuckets = []float64{.01, .1, .5, 1, 2.5, 5, 10, 30, 60, 120}
+)
+
+// Option configures the metrics.
+type Option func(*Metrics)
+
+// WithOperationBuckets sets the histogram buckets of the operation
+// durations, in seconds.
+func WithOperationBuckets(buckets ...float64) Option {
+	return func(m *Metrics) {
+		m.operationBuckets = sortedBuckets(buckets)
+	}
+}
+
+// WithConversationBuckets sets the histogram buckets of the conversation
+// durations, in seconds.
+func WithConversationBuckets(buckets ...float64) Option {
+	return func(m *Metrics) {
+		m.conversationBuckets = sortedBuckets(buckets)
+	}
+}
+
+func sortedBuckets(buckets []float64) []float64 {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return buckets
+}
+
+type operationKey struct {
+	operation, service string
+}
+
+type outcomeKey struct {
+	operation, service, result string
+}
+
+type conversationKey struct {
+	service, style string
+}
+
+// Metrics collects the metrics of the transactions it tracks. It's safe for
+// concurrent use.
+type Metrics struct {
+	operationBuckets    []float64
+	conversationBuckets []float64
+
+	mu            sync.Mutex
+	outcomes      map[outcomeKey]uint64
+	operations    map[operationKey]*histogram
+	conversations map[conversationKey]*histogram
+	// tracked are the open transactions.
+	tracked map[*pam.Transaction]bool
+}
+
+// New returns new metrics.
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		operationBuckets:    DefaultOperationBuckets,
+		conversationBuckets: DefaultConversationBuckets,
+		outcomes:            map[outcomeKey]uint64{},
+		operations:          map[operationKey]*histogram{},
+		conversations:       map[conversationKey]*histogram{},
+		tracked:             map[*pam.Transaction]bool{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Track adds hooks to the started transaction collecting its metrics. The
+// transaction is counted as open until it's ended. Transactions that are
+// already tracked, or that have ended, are left as they are.
+func (m *Metrics) Track(tx *pam.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tracked[tx] {
+		return
+	}
+	if tx.AddHooks(&hooks{m: m, tx: tx}) {
+		m.tracked[tx] = true
+	}
+}
+
+// hooks collect the metrics of a transaction.
+type hooks struct {
+	m  *Metrics
+	tx *pam.Transaction
+	// service is the service of the last operation, conversation events
+	// don't carry it.
+	service string
+}
+
+func (h *hooks) OnOperationStart(ctx context.Context, e *pam.OperationEvent) context.Context {
+	h.service = e.Service
+	return ctx
+}
+
+func (h *hooks) OnOperationEnd(ctx context.Context, e *pam.OperationEvent) {
+	m := h.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes[outcomeKey{e.Operation, e.Service, e.Status.Name()}]++
+	k := operationKey{e.Operation, e.Service}
+	hist := m.operations[k]
+	if hist == nil {
+		hist = newHistogram(m.operationBuckets)
+		m.operations[k] = hist
+	}
+	hist.observe(e.Duration.Seconds())
+}
+
+func (h *hooks) OnConversationStart(ctx context.Context, e *pam.ConversationEvent) context.Context {
+	return ctx
+}
+
+func (h *hooks) OnConversationEnd(ctx context.Context, e *pam.ConversationEvent) {
+	m := h.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := conversationKey{h.service, e.Style.String()}
+	hist := m.conversations[k]
+	if hist == nil {
+		hist = newHistogram(m.conversationBuckets)
+		m.conversations[k] = hist
+	}
+	hist.observe(e.Duration.Seconds())
+}
+
+func (h *hooks) OnEnd(ctx context.Context) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	delete(h.m.tracked, h.tx)
+}
+
+// Collect returns the current value of the metrics.
+func (m *Metrics) Collect() []Family {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	outcomes := Family{
+		Name: OperationsTotal,
+		Help: "Number of PAM operations by result.",
+		Type: CounterType,
+	}
+	for k, n := range m.outcomes {
+		outcomes.Samples = append(outcomes.Samples, Sample{
+			Labels: []Label{
+				{"operation", k.operation},
+				{"service", k.service},
+				{"result", k.result},
+			},
+			Value: float64(n),
+		})
+	}
+
+	operations := Family{
+		Name: OperationDuration,
+		Help: "Duration of the PAM operations in seconds.",
+		Type: HistogramType,
+	}
+	for k, hist := range m.operations {
+		operations.Samples = append(operations.Samples, hist.sample(
+			Label{"operation", k.operation},
+			Label{"service", k.service}))
+	}
+
+	conversations := Family{
+		Name: ConversationDuration,
+		Help: "Time spent waiting for the conversation handler in seconds.",
+		Type: HistogramType,
+	}
+	for k, hist := range m.conversations {
+		conversations.Samples = append(conversations.Samples, hist.sample(
+			Label{"service", k.service},
+			Label{"style", k.style}))
+	}
+
+	families := []Family{outcomes, operations, conversations, {
+		Name:    OpenTransactions,
+		Help:    "Number of PAM transactions that have not been ended.",
+		Type:    GaugeType,
+		Samples: []Sample{{Value: float64(len(m.tracked))}},
+	}}
+	for _, f := range families {
+		sortSamples(f.Samples)
+	}
+	return families
+}
+
+var _ Collector = (*Metrics)(nil)
+var _ pam.EndHooks = (*hooks)(nil)
+
+// histogram counts the observations falling in each bucket.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.count++
+	h.sum += v
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+}
+
+func (h *histogram) sample(labels ...Label) Sample {
+	s := Sample{Labels: labels, Value: h.sum, Count: h.count}
+	for i, b := range h.bounds {
+		s.Buckets = append(s.Buckets, Bucket{UpperBound: b, Count: h.counts[i]})
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package pammetrics
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/msteinert/pam/v2"
+)
+
+func TestMetrics(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	contents := "auth optional pam_echo.so Hello %u\n" +
+		"auth required pam_permit.so\n" +
+		"account required pam_deny.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "metrics-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+
+	m := New(WithOperationBuckets(10, 1))
+	m.Track(&pam.Transaction{})
+	for i := 0; i < 2; i++ {
+		tx, err := pam.StartConfDir("metrics-service", "testuser", pam.StaticCredentials{}, confDir)
+		if err != nil {
+			t.Fatalf("start #error: %v", err)
+		}
+		m.Track(tx)
+		m.Track(tx)
+		if err := tx.Authenticate(0); err != nil {
+			t.Fatalf("authenticate #error: %v", err)
+		}
+		if err := tx.AcctMgmt(0); !errors.Is(err, pam.ErrAuth) {
+			t.Fatalf("acctmgmt #unexpected error: %v", err)
+		}
+		if i == 0 {
+			if err := tx.End(); err != nil {
+				t.Fatalf("end #error: %v", err)
+			}
+			m.Track(tx)
+		} else {
+			defer tx.End()
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteText(&b, m); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	output := b.String()
+	for _, line := range []string{
+		`pam_operations_total{operation="pam_acct_mgmt",service="metrics-service",result="PAM_AUTH_ERR"} 2`,
+		`pam_operations_total{operation="pam_authenticate",service="metrics-service",result="PAM_SUCCESS"} 2`,
+		`pam_operation_duration_seconds_bucket{operation="pam_authenticate",service="metrics-service",le="1"} 2`,
+		`pam_operation_duration_seconds_bucket{operation="pam_authenticate",service="metrics-service",le="10"} 2`,
+		`pam_operation_duration_seconds_count{operation="pam_acct_mgmt",service="metrics-service"} 2`,
+		`pam_conversation_duration_seconds_count{service="metrics-service",style="TEXT_INFO"} 2`,
+		`pam_open_transactions 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("metrics #missing line %q in:\n%s", line, output)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package pamotel records the operations of PAM transactions, and the
+// conversations that happen during them, as OpenTelemetry spans.
+package pamotel
+
+import (
+	"context"
+
+	"github.com/msteinert/pam/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer used to create the spans.
+const TracerName = "github.com/msteinert/pam/v2/pamotel"
+
+// Span attributes.
+const (
+	OperationKey = attribute.Key("pam.operation")
+	ServiceKey   = attribute.Key("pam.service")
+	UserKey      = attribute.Key("pam.user")
+	RhostKey     = attribute.Key("pam.rhost")
+	FlagsKey     = attribute.Key("pam.flags")
+	ResultKey    = attribute.Key("pam.result")
+	StyleKey     = attribute.Key("pam.conversation.style")
+	TextKey      = attribute.Key("pam.conversation.text")
+)
+
+// ConversationSpanName is the name of the spans of the conversations, the
+// spans of the operations are named after the PAM functions.
+const ConversationSpanName = "pam_conv"
+
+type options struct {
+	provider trace.TracerProvider
+}
+
+// Option configures the hooks.
+type Option func(*options)
+
+// WithTracerProvider sets the provider of the tracer, the global one is used
+// by default.
+func WithTracerProvider(p trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = p
+	}
+}
+
+// Hooks are pam.Hooks creating spans, that are children of the context set
+// with Transaction.SetContext.
+type Hooks struct {
+	tracer trace.Tracer
+}
+
+// New returns the hooks to add to a transaction with Transaction.AddHooks.
+func New(opts ...Option) *Hooks {
+	o := options{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Hooks{tracer: o.provider.Tracer(TracerName)}
+}
+
+// OnOperationStart starts the span of an operation.
+func (h *Hooks) OnOperationStart(ctx context.Context, e *pam.OperationEvent) context.Context {
+	ctx, _ = h.tracer.Start(ctx, e.Operation,
+		trace.WithTimestamp(e.Start),
+		trace.WithAttributes(
+			OperationKey.String(e.Operation),
+			ServiceKey.String(e.Service),
+			UserKey.String(e.User),
+			RhostKey.String(e.Rhost),
+			FlagsKey.Int(int(e.Flags)),
+		))
+	return ctx
+}
+
+// OnOperationEnd ends the span of an operation.
+func (h *Hooks) OnOperationEnd(ctx context.Context, e *pam.OperationEvent) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(UserKey.String(e.User), ResultKey.String(e.Status.Name()))
+	if e.Status != 0 {
+		span.SetStatus(codes.Error, e.Status.Error())
+	}
+	span.End(trace.WithTimestamp(e.Start.Add(e.Duration)))
+}
+
+// OnConversationStart starts the span of a conversation message. The text
+// of the message is recorded, except for PromptEchoOff and BinaryPrompt.
+func (h *Hooks) OnConversationStart(ctx context.Context, e *pam.ConversationEvent) context.Context {
+	attrs := []attribute.KeyValue{StyleKey.String(e.Style.String())}
+	if e.Style != pam.PromptEchoOff && e.Style != pam.BinaryPrompt {
+		attrs = append(attrs, TextKey.String(e.Text))
+	}
+	ctx, _ = h.tracer.Start(ctx, ConversationSpanName,
+		trace.WithTimestamp(e.Start), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+// OnConversationEnd ends the span of a conversation message.
+func (h *Hooks) OnConversationEnd(ctx context.Context, e *pam.ConversationEvent) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(ResultKey.String(e.Status.Name()))
+	if e.Status != 0 {
+		span.SetStatus(codes.Error, e.Status.Error())
+	}
+	span.End(trace.WithTimestamp(e.Start.Add(e.Duration)))
+}
+
+var _ pam.Hooks = (*Hooks)(nil)
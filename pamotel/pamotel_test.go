@@ -0,0 +1,109 @@
+package pamotel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/msteinert/pam/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestHooks(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	confDir := t.TempDir()
+	contents := "auth optional pam_echo.so Hello %u\n" +
+		"auth required pam_permit.so\n" +
+		"account required pam_deny.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "otel-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "login")
+
+	tx, err := pam.StartConfDir("otel-service", "testuser", pam.StaticCredentials{}, confDir)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	defer tx.End()
+	tx.SetContext(ctx)
+	tx.AddHooks(New(WithTracerProvider(provider)))
+	if err := tx.SetItem(pam.Rhost, "example.com"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if err := tx.Authenticate(0); err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if err := tx.AcctMgmt(0); !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("acctmgmt #unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("spans #unexpected count: %d", len(spans))
+	}
+	conv, auth, acct := spans[0], spans[1], spans[2]
+
+	if auth.Name() != "pam_authenticate" || auth.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("spans #unexpected authenticate span: %v", auth.Name())
+	}
+	attrs := attributes(auth)
+	if attrs[ServiceKey].AsString() != "otel-service" ||
+		attrs[UserKey].AsString() != "testuser" ||
+		attrs[RhostKey].AsString() != "example.com" ||
+		attrs[ResultKey].AsString() != "PAM_SUCCESS" {
+		t.Fatalf("spans #unexpected authenticate attributes: %v", attrs)
+	}
+	if auth.Status().Code == codes.Error {
+		t.Fatalf("spans #unexpected authenticate status: %v", auth.Status())
+	}
+
+	if conv.Name() != ConversationSpanName || conv.Parent().SpanID() != auth.SpanContext().SpanID() {
+		t.Fatalf("spans #unexpected conversation span: %v", conv.Name())
+	}
+	attrs = attributes(conv)
+	if attrs[StyleKey].AsString() != "TEXT_INFO" || attrs[TextKey].AsString() != "Hello testuser" {
+		t.Fatalf("spans #unexpected conversation attributes: %v", attrs)
+	}
+
+	if acct.Name() != "pam_acct_mgmt" || acct.Status().Code != codes.Error ||
+		attributes(acct)[ResultKey].AsString() != "PAM_AUTH_ERR" {
+		t.Fatalf("spans #unexpected account span: %v, %v", acct.Name(), acct.Status())
+	}
+}
+
+func TestHooks_SecretPrompt(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	h := New(WithTracerProvider(provider))
+
+	e := &pam.ConversationEvent{Style: pam.PromptEchoOff, Text: "Password:"}
+	h.OnConversationEnd(h.OnConversationStart(context.Background(), e), e)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("spans #unexpected count: %d", len(spans))
+	}
+	if _, ok := attributes(spans[0])[TextKey]; ok {
+		t.Fatalf("spans #unexpected text attribute")
+	}
+}
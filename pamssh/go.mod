@@ -0,0 +1,15 @@
+module github.com/msteinert/pam/v2/pamssh
+
+go 1.21
+
+require (
+	github.com/msteinert/pam/v2 v2.0.0-00010101000000-000000000000
+	golang.org/x/crypto v0.21.0
+)
+
+require (
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/term v0.18.0 // indirect
+)
+
+replace github.com/msteinert/pam/v2 => ../
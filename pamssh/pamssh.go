@@ -0,0 +1,197 @@
+// Package pamssh provides a PAM backed keyboard-interactive authentication
+// callback for golang.org/x/crypto/ssh servers.
+package pamssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/msteinert/pam/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// Option configures the keyboard-interactive callback.
+type Option func(*options)
+
+type options struct {
+	confDir string
+	flags   pam.Flags
+	tty     string
+}
+
+// WithConfDir makes the callback look up the PAM service in confDir instead
+// of the system PAM configuration (see pam.StartConfDir).
+func WithConfDir(confDir string) Option {
+	return func(o *options) {
+		o.confDir = confDir
+	}
+}
+
+// WithFlags sets the flags passed to Authenticate and AcctMgmt.
+func WithFlags(f pam.Flags) Option {
+	return func(o *options) {
+		o.flags = f
+	}
+}
+
+// WithTty sets the Tty item of the transaction. It defaults to "ssh", that
+// is what OpenSSH uses.
+func WithTty(tty string) Option {
+	return func(o *options) {
+		o.tty = tty
+	}
+}
+
+// KeyboardInteractive returns a callback suitable for
+// ssh.ServerConfig.KeyboardInteractiveCallback that authenticates the
+// connecting user against the PAM service.
+//
+// Every authentication attempt runs in its own transaction: Authenticate and
+// AcctMgmt are invoked and, on success, the PAM environment is returned as
+// the Extensions of the ssh.Permissions.
+func KeyboardInteractive(service string, opts ...Option) func(ssh.ConnMetadata, ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	o := options{tty: "ssh"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		return authenticate(service, &o, conn, client)
+	}
+}
+
+func authenticate(service string, o *options, conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	c := &Conversation{User: conn.User(), Client: client}
+
+	var tx *pam.Transaction
+	var err error
+	if o.confDir == "" {
+		tx, err = pam.Start(service, conn.User(), c)
+	} else {
+		tx, err = pam.StartConfDir(service, conn.User(), c, o.confDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.End() }()
+
+	if host := remoteHost(conn.RemoteAddr()); host != "" {
+		if err := tx.SetItem(pam.Rhost, host); err != nil {
+			return nil, err
+		}
+	}
+	if o.tty != "" {
+		if err := tx.SetItem(pam.Tty, o.tty); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Authenticate(o.flags); err != nil {
+		_ = c.Flush()
+		return nil, err
+	}
+	if err := tx.AcctMgmt(o.flags); err != nil {
+		_ = c.Flush()
+		return nil, err
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+
+	env, err := tx.GetEnvList()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Permissions{Extensions: env}, nil
+}
+
+func remoteHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// Conversation is a pam.BatchConversationHandler that forwards PAM messages
+// to an SSH client using keyboard-interactive challenges.
+//
+// The PromptEchoOff and PromptEchoOn messages that a module sends at once are
+// sent as the questions of a single challenge, with the echo flags set
+// accordingly, while TextInfo and ErrorMsg messages are collected and
+// delivered as the instruction of the next challenge.
+type Conversation struct {
+	// User is the user name sent along with each challenge.
+	User string
+	// Client is the keyboard-interactive challenge of the connection.
+	Client ssh.KeyboardInteractiveChallenge
+
+	instruction []string
+}
+
+// RespondPAM handles a PAM conversation message.
+func (c *Conversation) RespondPAM(s pam.Style, msg string) (string, error) {
+	responses, err := c.RespondPAMBatch([]pam.Message{{Style: s, Msg: msg}})
+	if err != nil {
+		return "", err
+	}
+	return responses[0], nil
+}
+
+// RespondPAMBatch handles the PAM conversation messages, sending all the
+// prompts in a single challenge.
+func (c *Conversation) RespondPAMBatch(messages []pam.Message) ([]string, error) {
+	var questions []string
+	var echos []bool
+	var prompts []int
+	for i, m := range messages {
+		switch m.Style {
+		case pam.TextInfo, pam.ErrorMsg:
+			c.instruction = append(c.instruction, strings.TrimRight(m.Msg, "\n"))
+		case pam.PromptEchoOff, pam.PromptEchoOn:
+			questions = append(questions, m.Msg)
+			echos = append(echos, m.Style == pam.PromptEchoOn)
+			prompts = append(prompts, i)
+		default:
+			return nil, errors.New("unrecognized message style")
+		}
+	}
+
+	responses := make([]string, len(messages))
+	if len(questions) == 0 {
+		return responses, nil
+	}
+	answers, err := c.challenge(questions, echos)
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) != len(questions) {
+		return nil, fmt.Errorf("unexpected number of answers: %d", len(answers))
+	}
+	for i, answer := range answers {
+		responses[prompts[i]] = answer
+	}
+	return responses, nil
+}
+
+// Flush sends the pending TextInfo and ErrorMsg messages, if any, as a
+// challenge without questions.
+func (c *Conversation) Flush() error {
+	if len(c.instruction) == 0 {
+		return nil
+	}
+	_, err := c.challenge(nil, nil)
+	return err
+}
+
+func (c *Conversation) challenge(questions []string, echos []bool) ([]string, error) {
+	instruction := strings.Join(c.instruction, "\n")
+	c.instruction = nil
+	return c.Client(c.User, instruction, questions, echos)
+}
+
+var _ pam.BatchConversationHandler = (*Conversation)(nil)
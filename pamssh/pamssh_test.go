@@ -0,0 +1,149 @@
+package pamssh
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/msteinert/pam/v2"
+)
+
+type connMetadata struct {
+	user string
+}
+
+func (c connMetadata) User() string          { return c.user }
+func (c connMetadata) SessionID() []byte     { return nil }
+func (c connMetadata) ClientVersion() []byte { return nil }
+func (c connMetadata) ServerVersion() []byte { return nil }
+func (c connMetadata) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22022}
+}
+func (c connMetadata) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+}
+
+type challenge struct {
+	instruction string
+	questions   []string
+	echos       []bool
+}
+
+func TestConversation(t *testing.T) {
+	t.Parallel()
+	var challenges []challenge
+	var names []string
+	c := &Conversation{
+		User: "test",
+		Client: func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			names = append(names, name)
+			challenges = append(challenges, challenge{instruction, questions, echos})
+			answers := make([]string, len(questions))
+			for i, q := range questions {
+				answers[i] = "answer to " + q
+			}
+			return answers, nil
+		},
+	}
+
+	if r, err := c.RespondPAM(pam.TextInfo, "Welcome\n"); err != nil || r != "" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+	responses, err := c.RespondPAMBatch([]pam.Message{
+		{Style: pam.ErrorMsg, Msg: "Password expires soon"},
+		{Style: pam.PromptEchoOff, Msg: "Password: "},
+		{Style: pam.PromptEchoOn, Msg: "Code: "},
+	})
+	if err != nil {
+		t.Fatalf("respond #error: %v", err)
+	}
+	expected := []string{"", "answer to Password: ", "answer to Code: "}
+	if !reflect.DeepEqual(responses, expected) {
+		t.Fatalf("respond #unexpected responses: %q", responses)
+	}
+	if r, err := c.RespondPAM(pam.PromptEchoOn, "Name: "); err != nil || r != "answer to Name: " {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+	if _, err := c.RespondPAM(pam.BinaryPrompt, ""); err == nil {
+		t.Fatalf("respond #expected an error")
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("flush #error: %v", err)
+	}
+
+	expectedChallenges := []challenge{
+		{"Welcome\nPassword expires soon", []string{"Password: ", "Code: "}, []bool{false, true}},
+		{"", []string{"Name: "}, []bool{true}},
+	}
+	if !reflect.DeepEqual(challenges, expectedChallenges) {
+		t.Fatalf("challenge #unexpected challenges: %#v", challenges)
+	}
+	if !reflect.DeepEqual(names, []string{"test", "test"}) {
+		t.Fatalf("challenge #unexpected users: %v", names)
+	}
+}
+
+func TestConversation_ClientError(t *testing.T) {
+	t.Parallel()
+	c := &Conversation{
+		Client: func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			return nil, errors.New("disconnected")
+		},
+	}
+	if _, err := c.RespondPAM(pam.PromptEchoOff, "Password: "); err == nil {
+		t.Fatalf("respond #expected an error")
+	}
+}
+
+func TestKeyboardInteractive(t *testing.T) {
+	if !pam.CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	u, _ := user.Current()
+	var instruction string
+	var questions []string
+	client := func(name, instr string, q []string, echos []bool) ([]string, error) {
+		// This runs in the PAM conversation, record what to check instead
+		// of failing the test from there.
+		instruction = instr
+		questions = append(questions, q...)
+		return make([]string, len(q)), nil
+	}
+
+	confDir := t.TempDir()
+	contents := "auth optional pam_echo.so Hello %u from %H on %t\n" +
+		"auth required pam_permit.so\n" +
+		"account required pam_permit.so\n"
+	if err := os.WriteFile(filepath.Join(confDir, "ssh-service"),
+		[]byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file: %v", err)
+	}
+
+	cb := KeyboardInteractive("ssh-service", WithConfDir(confDir))
+	perms, err := cb(connMetadata{u.Username}, client)
+	if err != nil {
+		t.Fatalf("keyboard-interactive #error: %v", err)
+	}
+	if perms == nil {
+		t.Fatalf("keyboard-interactive #expected permissions")
+	}
+	if len(questions) != 0 {
+		t.Fatalf("challenge #unexpected questions: %v", questions)
+	}
+	if instruction != "Hello "+u.Username+" from 192.0.2.1 on ssh" {
+		t.Fatalf("keyboard-interactive #unexpected instruction: %q", instruction)
+	}
+
+	cb = KeyboardInteractive("deny-service", WithConfDir("../test-services"))
+	perms, err = cb(connMetadata{u.Username}, client)
+	if !errors.Is(err, pam.ErrAuth) {
+		t.Fatalf("keyboard-interactive #unexpected error: %v", err)
+	}
+	if perms != nil {
+		t.Fatalf("keyboard-interactive #unexpected permissions: %v", perms)
+	}
+}
@@ -0,0 +1,188 @@
+package pam
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errPasswordRejected is returned by the conversation to stop the modules
+// from asking again for a new password that has been rejected.
+var errPasswordRejected = errors.New("new password rejected")
+
+// PasswordChangeError is returned by ChangePassword when the password could
+// not be changed.
+type PasswordChangeError struct {
+	// Err is the error returned by ChangeAuthTok.
+	Err error
+	// Rejections are the reasons given by the modules, such as
+	// pam_pwquality, for rejecting the new password, without their
+	// "BAD PASSWORD:" prefix.
+	Rejections []string
+	// Messages are the other error messages sent by the modules.
+	Messages []string
+}
+
+func (e *PasswordChangeError) Error() string {
+	reasons := append(append([]string(nil), e.Rejections...), e.Messages...)
+	if len(reasons) == 0 {
+		return fmt.Sprintf("password change failed: %v", e.Err)
+	}
+	return fmt.Sprintf("password change failed: %v: %s", e.Err,
+		strings.Join(reasons, "; "))
+}
+
+func (e *PasswordChangeError) Unwrap() error {
+	return e.Err
+}
+
+// passwordConversation answers the prompts of a password change.
+//
+// Linux-PAM runs the modules twice: first with PAM_PRELIM_CHECK, where they
+// usually ask for the current password, then with PAM_UPDATE_AUTHTOK, where
+// they ask for the new one and to retype it. The phase isn't visible to the
+// application, so the prompts are recognized using ClassifyPrompt.
+type passwordConversation struct {
+	oldPassword, newPassword string
+	// newSent is whether the new password has been sent.
+	newSent bool
+	// pending are the error messages received since the new password was
+	// sent. They are the reasons of a rejection if the modules ask for a new
+	// password again, and only warnings if they ask to retype it, as
+	// pam_pwquality does when it's not enforcing.
+	pending    []string
+	rejections []string
+	messages   []string
+}
+
+func (c *passwordConversation) RespondPAM(s Style, msg string) (string, error) {
+	switch s {
+	case ErrorMsg:
+		if c.newSent {
+			c.pending = append(c.pending, msg)
+		} else {
+			c.messages = append(c.messages, msg)
+		}
+		return "", nil
+	case TextInfo:
+		return "", nil
+	}
+
+	switch ClassifyPrompt(s, msg) {
+	case PromptNewPassword:
+		if c.newSent {
+			for _, m := range c.pending {
+				c.rejections = append(c.rejections, strings.TrimSpace(
+					strings.TrimPrefix(m, "BAD PASSWORD:")))
+			}
+			c.pending = nil
+			return "", fmt.Errorf("%w: %w", ErrConv, errPasswordRejected)
+		}
+		c.newSent = true
+		return c.newPassword, nil
+	case PromptConfirmNewPassword:
+		if !c.newSent {
+			return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+		}
+		c.flushPending()
+		return c.newPassword, nil
+	case PromptPassword:
+		return c.oldPassword, nil
+	}
+	return "", fmt.Errorf("%w: unexpected prompt %q", ErrConv, msg)
+}
+
+// flushPending moves the pending error messages to the other messages, as
+// they didn't reject the new password.
+func (c *passwordConversation) flushPending() {
+	c.messages = append(c.messages, c.pending...)
+	c.pending = nil
+}
+
+// ChangePassword changes the password of the user, answering the prompts for
+// the current password with oldPassword and the ones for the new password,
+// and to retype it, with newPassword. The conversation handler of the
+// transaction isn't used during the change.
+//
+// It reports whether the password was changed, that is whether ChangeAuthTok
+// succeeded after the modules asked for the new password. It returns false
+// with a nil error when they succeeded without asking for it, in which case
+// the password wasn't changed to newPassword. On failure the error is a
+// *PasswordChangeError, holding the reasons why the new password was
+// rejected, if any. The error messages sent after the new password count as
+// reasons only when the modules ask for a new password again, which isn't
+// answered with the same one.
+func (t *Transaction) ChangePassword(oldPassword, newPassword string) (bool, error) {
+	return t.changePassword(oldPassword, newPassword, 0)
+}
+
+func (t *Transaction) changePassword(oldPassword, newPassword string, f Flags) (bool, error) {
+	c := &passwordConversation{oldPassword: oldPassword, newPassword: newPassword}
+	err := t.withConversation(c, func() error {
+		return t.ChangeAuthTok(f)
+	})
+	c.flushPending()
+	if err != nil {
+		return false, &PasswordChangeError{
+			Err:        err,
+			Rejections: c.rejections,
+			Messages:   c.messages,
+		}
+	}
+	return c.newSent, nil
+}
+
+// withConversation runs fn using handler as conversation handler in place of
+// the one of the transaction.
+func (t *Transaction) withConversation(handler ConversationHandler, fn func() error) error {
+	if t.callbacks == nil {
+		return fn()
+	}
+	previous := t.callbacks.handler
+	t.callbacks.handler = handler
+	defer func() { t.callbacks.handler = previous }()
+	return fn()
+}
+
+// PasswordExpiredHandler is a conversation handler that can provide the
+// passwords to use when the password of the user has expired.
+type PasswordExpiredHandler interface {
+	ConversationHandler
+	// OnPasswordExpired returns the current and the new password to change
+	// the expired password with.
+	OnPasswordExpired() (oldPassword, newPassword string, err error)
+}
+
+// Login authenticates the user and validates the account, as Authenticate
+// and AcctMgmt do. If AcctMgmt fails with ErrNewAuthtokReqd and the
+// conversation handler is a PasswordExpiredHandler, the expired password is
+// changed with ChangePassword using its passwords and the ChangeExpiredAuthtok
+// flag.
+//
+// It reports whether the expired password was changed. When the handler
+// can't provide the passwords, the error matches ErrNewAuthtokReqd, and when
+// the change fails it's a *PasswordChangeError.
+//
+// Valid flags: Silent, DisallowNullAuthtok.
+func (t *Transaction) Login(f Flags) (bool, error) {
+	if err := t.Authenticate(f); err != nil {
+		return false, err
+	}
+	err := t.AcctMgmt(f)
+	if !errors.Is(err, ErrNewAuthtokReqd) {
+		return false, err
+	}
+	var handler ConversationHandler
+	if t.callbacks != nil {
+		handler = t.callbacks.handler
+	}
+	h, ok := handler.(PasswordExpiredHandler)
+	if !ok {
+		return false, err
+	}
+	oldPassword, newPassword, herr := h.OnPasswordExpired()
+	if herr != nil {
+		return false, fmt.Errorf("%w: %w", err, herr)
+	}
+	return t.changePassword(oldPassword, newPassword, f&Silent|ChangeExpiredAuthtok)
+}
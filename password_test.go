@@ -0,0 +1,210 @@
+//go:build cgo && linux && !openpam
+
+package pam
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s oldauthtok=secret authtok=newsecret "+
+			moduleArg("info=Changing password"))
+
+	changed, err := tx.ChangePassword("secret", "newsecret")
+	if err != nil {
+		t.Fatalf("changepassword #error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("changepassword #expected the password to be changed")
+	}
+}
+
+func TestChangePassword_NoPrompt(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s")
+
+	changed, err := tx.ChangePassword("secret", "newsecret")
+	if err != nil {
+		t.Fatalf("changepassword #error: %v", err)
+	}
+	if changed {
+		t.Fatalf("changepassword #unexpected change")
+	}
+}
+
+func TestChangePassword_Warning(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s oldauthtok=secret "+
+			moduleArg("echo_off=New password: ")+" expect=abccba "+
+			moduleArg("error=BAD PASSWORD: The password is a palindrome")+" "+
+			moduleArg("echo_off=Retype new password: ")+" expect=abccba "+
+			"chauthtok=authtok_err")
+
+	changed, err := tx.ChangePassword("secret", "abccba")
+	var changeErr *PasswordChangeError
+	if changed || !errors.As(err, &changeErr) || !errors.Is(err, ErrAuthtok) {
+		t.Fatalf("changepassword #unexpected result: %v, %v", changed, err)
+	}
+	if len(changeErr.Rejections) != 0 {
+		t.Fatalf("changepassword #unexpected rejections: %q", changeErr.Rejections)
+	}
+	expected := []string{"BAD PASSWORD: The password is a palindrome"}
+	if !reflect.DeepEqual(changeErr.Messages, expected) {
+		t.Fatalf("changepassword #unexpected messages: %q", changeErr.Messages)
+	}
+
+	tx = startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s oldauthtok=secret "+
+			moduleArg("echo_off=New password: ")+" expect=abccba "+
+			moduleArg("error=BAD PASSWORD: The password is a palindrome")+" "+
+			moduleArg("echo_off=Retype new password: ")+" expect=abccba")
+	if changed, err := tx.ChangePassword("secret", "abccba"); !changed || err != nil {
+		t.Fatalf("changepassword #unexpected result: %v, %v", changed, err)
+	}
+}
+
+func TestChangePassword_WrongPassword(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s oldauthtok=secret authtok=newsecret")
+
+	changed, err := tx.ChangePassword("wrong", "newsecret")
+	var changeErr *PasswordChangeError
+	if !errors.As(err, &changeErr) || !errors.Is(err, ErrAuth) {
+		t.Fatalf("changepassword #unexpected error: %v", err)
+	}
+	if changed || len(changeErr.Rejections) != 0 {
+		t.Fatalf("changepassword #unexpected result: %v, %v", changed, changeErr)
+	}
+}
+
+func TestChangePassword_Rejected(t *testing.T) {
+	t.Parallel()
+	tx := startGoTestModule(t, "testuser", StaticCredentials{},
+		"password requisite %[1]s oldauthtok=secret "+
+			moduleArg("error=Password expires soon")+" "+
+			moduleArg("echo_off=New password: ")+" "+
+			moduleArg("error=BAD PASSWORD: The password is shorter than 8 characters")+" "+
+			moduleArg("echo_off=New password: "))
+
+	changed, err := tx.ChangePassword("secret", "short")
+	var changeErr *PasswordChangeError
+	if !errors.As(err, &changeErr) || !errors.Is(err, ErrConv) {
+		t.Fatalf("changepassword #unexpected error: %v", err)
+	}
+	if changed {
+		t.Fatalf("changepassword #unexpected change")
+	}
+	expected := []string{"The password is shorter than 8 characters"}
+	if !reflect.DeepEqual(changeErr.Rejections, expected) {
+		t.Fatalf("changepassword #unexpected rejections: %q", changeErr.Rejections)
+	}
+	expected = []string{"Password expires soon"}
+	if !reflect.DeepEqual(changeErr.Messages, expected) {
+		t.Fatalf("changepassword #unexpected messages: %q", changeErr.Messages)
+	}
+}
+
+func TestChangePassword_RestoresHandler(t *testing.T) {
+	t.Parallel()
+	c := StaticCredentials{Password: "secret", NewPassword: "other"}
+	tx := startGoTestModule(t, "testuser", c,
+		"password requisite %[1]s oldauthtok=secret authtok=other")
+
+	if _, err := tx.ChangePassword("secret", "newsecret"); !errors.Is(err, ErrAuth) {
+		t.Fatalf("changepassword #unexpected error: %v", err)
+	}
+	if err := tx.ChangeAuthTok(0); err != nil {
+		t.Fatalf("chauthtok #error: %v", err)
+	}
+}
+
+func TestFailure_ChangePassword(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	changed, err := tx.ChangePassword("secret", "newsecret")
+	var changeErr *PasswordChangeError
+	if changed || !errors.As(err, &changeErr) {
+		t.Fatalf("changepassword #unexpected result: %v, %v", changed, err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"auth requisite %[1]s authtok=secret",
+		"account requisite %[1]s acct=new_authtok_reqd",
+		"password requisite %[1]s oldauthtok=secret authtok=newsecret",
+	}
+	c := StaticCredentials{Password: "secret", NewPassword: "newsecret"}
+	tx := startGoTestModule(t, "testuser", c, append([]string(nil), lines...)...)
+	changed, err := tx.Login(0)
+	if err != nil {
+		t.Fatalf("login #error: %v", err)
+	}
+	if !changed {
+		t.Fatalf("login #expected the password to be changed")
+	}
+
+	c.NewPassword = "short"
+	tx = startGoTestModule(t, "testuser", c, append([]string(nil), lines...)...)
+	changed, err = tx.Login(0)
+	var changeErr *PasswordChangeError
+	if changed || !errors.As(err, &changeErr) || !errors.Is(err, ErrAuth) {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+
+	c.NewPassword = ""
+	tx = startGoTestModule(t, "testuser", c, append([]string(nil), lines...)...)
+	if changed, err := tx.Login(0); changed || !errors.Is(err, ErrNewAuthtokReqd) {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+}
+
+func TestLogin_NotExpired(t *testing.T) {
+	t.Parallel()
+	lines := []string{
+		"auth requisite %[1]s authtok=secret",
+		"account requisite %[1]s",
+		"password requisite %[1]s chauthtok=authtok_err",
+	}
+	c := StaticCredentials{Password: "secret", NewPassword: "newsecret"}
+	tx := startGoTestModule(t, "testuser", c, append([]string(nil), lines...)...)
+	if changed, err := tx.Login(0); changed || err != nil {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+
+	c.Password = "wrong"
+	tx = startGoTestModule(t, "testuser", c, append([]string(nil), lines...)...)
+	if changed, err := tx.Login(0); changed || !errors.Is(err, ErrAuth) {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+}
+
+func TestLogin_NoExpiredHandler(t *testing.T) {
+	t.Parallel()
+	handler := ConversationFunc(func(s Style, msg string) (string, error) {
+		return "secret", nil
+	})
+	tx := startGoTestModule(t, "testuser", handler,
+		"auth requisite %[1]s authtok=secret",
+		"account requisite %[1]s acct=new_authtok_reqd",
+		"password requisite %[1]s")
+	if changed, err := tx.Login(0); changed || !errors.Is(err, ErrNewAuthtokReqd) {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+}
+
+func TestFailure_Login(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	if changed, err := tx.Login(0); changed || err == nil {
+		t.Fatalf("login #unexpected result: %v, %v", changed, err)
+	}
+}
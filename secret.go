@@ -0,0 +1,74 @@
+package pam
+
+// Secret is a sensitive value, such as a password, that is stored in memory
+// which is locked, so that it's never swapped, and excluded from core dumps
+// where supported. Memory that can't be locked, because the process exceeds
+// its RLIMIT_MEMLOCK, is still used, but may be swapped.
+//
+// A Secret must be released with Wipe once not needed anymore, and it must
+// not be re-sliced, otherwise its memory can't be released.
+type Secret []byte
+
+// NewSecret allocates a Secret of the given size.
+func NewSecret(size int) (Secret, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+	b, err := allocLocked(size)
+	if err != nil {
+		return nil, err
+	}
+	excludeFromDump(b)
+	return Secret(b), nil
+}
+
+// SecretFromBytes returns a Secret with a copy of b, that is zeroed.
+func SecretFromBytes(b []byte) (Secret, error) {
+	defer wipeBytes(b)
+	s, err := NewSecret(len(b))
+	if err != nil {
+		return nil, err
+	}
+	copy(s, b)
+	return s, nil
+}
+
+// Wipe zeroes the secret and releases its memory. The Secret must not be
+// used afterwards.
+func (s *Secret) Wipe() {
+	if *s == nil {
+		return
+	}
+	b := []byte((*s)[:cap(*s)])
+	*s = nil
+	wipeBytes(b)
+	freeLocked(b)
+}
+
+// String hides the value of the secret, so that it's not leaked by mistake.
+func (s Secret) String() string {
+	return "<secret>"
+}
+
+// GoString hides the value of the secret, so that it's not leaked by
+// mistake.
+func (s Secret) GoString() string {
+	return "pam.Secret{<secret>}"
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// SecretConversationHandler is a ConversationHandler that provides the
+// responses to the prompts as Secret values, so that they're never copied
+// into memory that can't be wiped.
+type SecretConversationHandler interface {
+	ConversationHandler
+	// RespondPAMSecret is used instead of RespondPAM for the PromptEchoOff
+	// and PromptEchoOn messages. The returned Secret is wiped once it has
+	// been passed to the module.
+	RespondPAMSecret(Style, string) (Secret, error)
+}
@@ -0,0 +1,11 @@
+//go:build freebsd
+
+package pam
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func excludeFromDump(b []byte) {
+	_ = unix.Madvise(b, unix.MADV_NOCORE)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package pam
+
+// allocLocked allocates the memory for a secret, memory locking is not
+// supported on this platform.
+func allocLocked(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+// freeLocked releases memory allocated by allocLocked.
+func freeLocked(b []byte) {}
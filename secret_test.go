@@ -0,0 +1,74 @@
+//go:build cgo
+
+package pam
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	t.Parallel()
+	b := []byte("secret")
+	s, err := SecretFromBytes(b)
+	if err != nil {
+		t.Fatalf("secret #error: %v", err)
+	}
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("secret #source not wiped: %q", b)
+	}
+	if string(s) != "secret" {
+		t.Fatalf("secret #unexpected value: %q", []byte(s))
+	}
+	if str := fmt.Sprintf("%v %s %#v", s, s, s); strings.Contains(str, "secret ") ||
+		strings.Contains(str, "[") {
+		t.Fatalf("secret #value leaked: %v", str)
+	}
+	s.Wipe()
+	if s != nil {
+		t.Fatalf("secret #not released")
+	}
+	s.Wipe()
+
+	s, err = NewSecret(0)
+	if err != nil || s != nil {
+		t.Fatalf("secret #unexpected result: %v, %v", s, err)
+	}
+	s.Wipe()
+}
+
+type secretCredentials struct {
+	StaticCredentials
+	secrets int
+}
+
+func (c *secretCredentials) RespondPAMSecret(s Style, msg string) (Secret, error) {
+	c.secrets++
+	r, err := c.RespondPAM(s, msg)
+	if err != nil {
+		return nil, err
+	}
+	return SecretFromBytes([]byte(r))
+}
+
+func TestPAM_ConfDir_SecretConversation(t *testing.T) {
+	if !CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	c := &secretCredentials{StaticCredentials: StaticCredentials{User: "testuser"}}
+	tx, err := StartConfDir("succeed-if-user-test", "", c, "test-services")
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+	err = tx.Authenticate(0)
+	if err != nil {
+		t.Fatalf("authenticate #error: %v", err)
+	}
+	if c.secrets != 1 {
+		t.Fatalf("authenticate #unexpected secret responses: %v", c.secrets)
+	}
+}
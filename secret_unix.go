@@ -0,0 +1,29 @@
+//go:build unix
+
+package pam
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// mlock locks memory, it's replaced by tests.
+var mlock = unix.Mlock
+
+// allocLocked allocates memory that is locked, so that it's never swapped.
+// If locking fails, for example because RLIMIT_MEMLOCK is too small, as in
+// most containers, the memory is returned unlocked.
+func allocLocked(size int) ([]byte, error) {
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE,
+		unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	_ = mlock(b)
+	return b, nil
+}
+
+// freeLocked releases memory allocated by allocLocked.
+func freeLocked(b []byte) {
+	_ = unix.Munlock(b)
+	_ = unix.Munmap(b)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package pam
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNewSecret_MlockFailure(t *testing.T) {
+	mlock = func([]byte) error { return unix.ENOMEM }
+	defer func() { mlock = unix.Mlock }()
+
+	s, err := NewSecret(8)
+	if err != nil {
+		t.Fatalf("newsecret #error: %v", err)
+	}
+	copy(s, "password")
+	s.Wipe()
+}
@@ -0,0 +1,95 @@
+package pam
+
+import "fmt"
+
+// errorMessages are the messages of the errors, as Linux-PAM reports them in
+// English. Unlike pam_strerror, they don't depend on the PAM implementation
+// nor on the locale, so they're stable in logs and tests.
+var errorMessages = map[Error]string{
+	success:                "Success",
+	ErrOpen:                "Failed to load module",
+	ErrSymbol:              "Symbol not found",
+	ErrService:             "Error in service module",
+	ErrSystem:              "System error",
+	ErrBuf:                 "Memory buffer error",
+	ErrPermDenied:          "Permission denied",
+	ErrAuth:                "Authentication failure",
+	ErrCredInsufficient:    "Insufficient credentials to access authentication data",
+	ErrAuthinfoUnavail:     "Authentication service cannot retrieve authentication info",
+	ErrUserUnknown:         "User not known to the underlying authentication module",
+	ErrMaxtries:            "Have exhausted maximum number of retries for service",
+	ErrNewAuthtokReqd:      "Authentication token is no longer valid; new one required",
+	ErrAcctExpired:         "User account has expired",
+	ErrSession:             "Cannot make/remove an entry for the specified session",
+	ErrCredUnavail:         "Authentication service cannot retrieve user credentials",
+	ErrCredExpired:         "User credentials expired",
+	ErrCred:                "Failure setting user credentials",
+	ErrNoModuleData:        "No module specific data is present",
+	ErrConv:                "Conversation error",
+	ErrAuthtok:             "Authentication token manipulation error",
+	ErrAuthtokRecovery:     "Authentication information cannot be recovered",
+	ErrAuthtokLockBusy:     "Authentication token lock busy",
+	ErrAuthtokDisableAging: "Authentication token aging disabled",
+	ErrTryAgain:            "Failed preliminary check by password service",
+	ErrIgnore:              "The return value should be ignored by PAM dispatch",
+	ErrAbort:               "Critical error - immediate abort",
+	ErrAuthtokExpired:      "Authentication token expired",
+	ErrModuleUnknown:       "Module is unknown",
+}
+
+// errorNames are the names of the errors' constants.
+var errorNames = map[Error]string{
+	success:                "PAM_SUCCESS",
+	ErrOpen:                "PAM_OPEN_ERR",
+	ErrSymbol:              "PAM_SYMBOL_ERR",
+	ErrService:             "PAM_SERVICE_ERR",
+	ErrSystem:              "PAM_SYSTEM_ERR",
+	ErrBuf:                 "PAM_BUF_ERR",
+	ErrPermDenied:          "PAM_PERM_DENIED",
+	ErrAuth:                "PAM_AUTH_ERR",
+	ErrCredInsufficient:    "PAM_CRED_INSUFFICIENT",
+	ErrAuthinfoUnavail:     "PAM_AUTHINFO_UNAVAIL",
+	ErrUserUnknown:         "PAM_USER_UNKNOWN",
+	ErrMaxtries:            "PAM_MAXTRIES",
+	ErrNewAuthtokReqd:      "PAM_NEW_AUTHTOK_REQD",
+	ErrAcctExpired:         "PAM_ACCT_EXPIRED",
+	ErrSession:             "PAM_SESSION_ERR",
+	ErrCredUnavail:         "PAM_CRED_UNAVAIL",
+	ErrCredExpired:         "PAM_CRED_EXPIRED",
+	ErrCred:                "PAM_CRED_ERR",
+	ErrNoModuleData:        "PAM_NO_MODULE_DATA",
+	ErrConv:                "PAM_CONV_ERR",
+	ErrAuthtok:             "PAM_AUTHTOK_ERR",
+	ErrAuthtokRecovery:     "PAM_AUTHTOK_RECOVERY_ERR",
+	ErrAuthtokLockBusy:     "PAM_AUTHTOK_LOCK_BUSY",
+	ErrAuthtokDisableAging: "PAM_AUTHTOK_DISABLE_AGING",
+	ErrTryAgain:            "PAM_TRY_AGAIN",
+	ErrIgnore:              "PAM_IGNORE",
+	ErrAbort:               "PAM_ABORT",
+	ErrAuthtokExpired:      "PAM_AUTHTOK_EXPIRED",
+	ErrModuleUnknown:       "PAM_MODULE_UNKNOWN",
+}
+
+// Error returns the error message for the given status. Use
+// Transaction.StrError for the message provided by PAM.
+func (status Error) Error() string {
+	if msg, ok := errorMessages[status]; ok {
+		return msg
+	}
+	if msg, ok := platformErrorMessages[status]; ok {
+		return msg
+	}
+	return "Unknown PAM error"
+}
+
+// Name returns the name of the PAM constant of the status, such as
+// PAM_AUTH_ERR.
+func (status Error) Name() string {
+	if name, ok := errorNames[status]; ok {
+		return name
+	}
+	if name, ok := platformErrorNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("PAM_UNKNOWN_ERR(%d)", int(status))
+}
@@ -0,0 +1,197 @@
+package pam
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// TerminalConversation is a conversation handler that interacts with the
+// user through a terminal.
+//
+// PromptEchoOff responses are read from the terminal Fd with echo disabled,
+// while PromptEchoOn responses are read as lines from In. TextInfo messages
+// are written to Out and ErrorMsg messages to Err.
+//
+// If the user presses Ctrl-C while a response is being read from a terminal,
+// or from In if it's an *os.File, the conversation fails and the read is
+// cancelled, so that the following input isn't consumed. Reads from other
+// readers, and on platforms other than Unix, can't be interrupted.
+type TerminalConversation struct {
+	// In is where the responses are read from.
+	In io.Reader
+	// Out is where the prompts and the TextInfo messages are written to.
+	Out io.Writer
+	// Err is where the ErrorMsg messages are written to.
+	Err io.Writer
+	// Fd is the file descriptor of the terminal used to read the
+	// PromptEchoOff responses without echoing them. As its zero value is
+	// the standard input, it's only used by the conversations returned by
+	// NewTerminalConversation, or when In is an *os.File with this
+	// descriptor. Otherwise, or if Fd is not a terminal, the responses are
+	// read from In.
+	Fd int
+	// Silent indicates that TextInfo and ErrorMsg messages should not be
+	// displayed.
+	Silent bool
+
+	mu     sync.Mutex
+	reader *bufio.Reader
+	// hasFd is set by NewTerminalConversation, whose Fd is always used.
+	hasFd bool
+}
+
+// NewTerminalConversation returns a TerminalConversation using the standard
+// input, output and error streams of the process.
+func NewTerminalConversation() *TerminalConversation {
+	return &TerminalConversation{
+		In:  os.Stdin,
+		Out: os.Stdout,
+		Err: os.Stderr,
+		Fd:  int(os.Stdin.Fd()),
+
+		hasFd: true,
+	}
+}
+
+// RespondPAM handles PAM conversation messages.
+func (c *TerminalConversation) RespondPAM(s Style, msg string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch s {
+	case PromptEchoOff:
+		if _, err := fmt.Fprint(c.Out, msg); err != nil {
+			return "", err
+		}
+		pw, err := c.readPassword()
+		if err != nil {
+			return "", err
+		}
+		defer wipeBytes(pw)
+		return string(pw), nil
+	case PromptEchoOn:
+		if _, err := fmt.Fprint(c.Out, msg); err != nil {
+			return "", err
+		}
+		return c.readLine()
+	case ErrorMsg:
+		if !c.Silent {
+			fmt.Fprintln(c.Err, strings.TrimRight(msg, "\n"))
+		}
+		return "", nil
+	case TextInfo:
+		if !c.Silent {
+			fmt.Fprintln(c.Out, strings.TrimRight(msg, "\n"))
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("%w: unexpected message style %d", ErrConv, s)
+}
+
+// RespondPAMSecret handles PAM conversation prompts, returning the
+// responses as Secret values.
+func (c *TerminalConversation) RespondPAMSecret(s Style, msg string) (Secret, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch s {
+	case PromptEchoOff:
+		if _, err := fmt.Fprint(c.Out, msg); err != nil {
+			return nil, err
+		}
+		pw, err := c.readPassword()
+		if err != nil {
+			return nil, err
+		}
+		return SecretFromBytes(pw)
+	case PromptEchoOn:
+		if _, err := fmt.Fprint(c.Out, msg); err != nil {
+			return nil, err
+		}
+		r, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		return SecretFromBytes([]byte(r))
+	}
+	return nil, fmt.Errorf("%w: unexpected message style %d", ErrConv, s)
+}
+
+// readPassword reads a PromptEchoOff response, the caller is responsible of
+// wiping it.
+func (c *TerminalConversation) readPassword() ([]byte, error) {
+	if !c.useFd() || !term.IsTerminal(c.Fd) {
+		return c.readBytes()
+	}
+	pw, err := readTerminalPassword(c.Fd)
+	fmt.Fprintln(c.Out)
+	return pw, err
+}
+
+// useFd reports whether Fd was explicitly chosen as the terminal, rather
+// than being left to its zero value.
+func (c *TerminalConversation) useFd() bool {
+	if c.hasFd {
+		return true
+	}
+	f, ok := c.In.(*os.File)
+	return ok && int(f.Fd()) == c.Fd
+}
+
+func (c *TerminalConversation) readLine() (string, error) {
+	b, err := c.readBytes()
+	if err != nil {
+		return "", err
+	}
+	defer wipeBytes(b)
+	return string(b), nil
+}
+
+// readBytes reads a line from In, the caller is responsible of wiping it.
+func (c *TerminalConversation) readBytes() ([]byte, error) {
+	if f, ok := c.In.(*os.File); ok {
+		return readFileLine(f)
+	}
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.In)
+	}
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		wipeBytes(line)
+		return nil, err
+	}
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	return trimCR(line), nil
+}
+
+var errInterrupted = fmt.Errorf("%w: interrupted", ErrConv)
+
+// trimCR removes the carriage return of a line terminated by CRLF.
+func trimCR(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line[n-1] = 0
+		return line[:n-1]
+	}
+	return line
+}
+
+// appendByte appends c to line, wiping the previous storage of the line if
+// it has to be reallocated.
+func appendByte(line []byte, c byte) []byte {
+	if len(line) < cap(line) {
+		return append(line, c)
+	}
+	grown := make([]byte, len(line), 2*cap(line)+1)
+	copy(grown, line)
+	wipeBytes(line)
+	return append(grown, c)
+}
@@ -0,0 +1,101 @@
+package pam
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the controller and the terminal of a new pseudo-terminal.
+func openPty(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("can't open a pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { ptmx.Close() })
+	fd := int(ptmx.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unlockpt #error: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("ptsname #error: %v", err)
+	}
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatalf("can't open the terminal: %v", err)
+	}
+	t.Cleanup(func() { tty.Close() })
+	return ptmx, tty
+}
+
+func TestTerminalConversation_Terminal(t *testing.T) {
+	t.Parallel()
+	ptmx, tty := openPty(t)
+	var out bytes.Buffer
+	c := &TerminalConversation{In: tty, Out: &out, Err: &out, Fd: int(tty.Fd())}
+
+	// The password is typed once the echo is disabled, as the terminal
+	// echoes the input when it's received.
+	fd := int(tty.Fd())
+	go func() {
+		for {
+			termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+			if err != nil || termios.Lflag&unix.ECHO == 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		_, _ = ptmx.Write([]byte("secret\n"))
+	}()
+	if r, err := c.RespondPAM(PromptEchoOff, "Password: "); err != nil || r != "secret" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+	if _, err := ptmx.Write([]byte("user\n")); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	if r, err := c.RespondPAM(PromptEchoOn, "login: "); err != nil || r != "user" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+
+	// Only the second line is echoed, and the echo is restored.
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil || termios.Lflag&unix.ECHO == 0 {
+		t.Fatalf("termios #echo not restored: %v", err)
+	}
+	echo := make([]byte, 256)
+	n, err := ptmx.Read(echo)
+	if err != nil {
+		t.Fatalf("read #error: %v", err)
+	}
+	if bytes.Contains(echo[:n], []byte("secret")) || !bytes.Contains(echo[:n], []byte("user")) {
+		t.Fatalf("terminal #unexpected echo: %q", echo[:n])
+	}
+}
+
+func TestTerminalConversation_FdOfOtherInput(t *testing.T) {
+	t.Parallel()
+	_, tty := openPty(t)
+	var out bytes.Buffer
+	c := &TerminalConversation{
+		In:  strings.NewReader("secret\n"),
+		Out: &out,
+		Err: &out,
+		Fd:  int(tty.Fd()),
+	}
+
+	// Fd isn't the descriptor of In, so it's not used: reading from the
+	// terminal would block.
+	if r, err := c.RespondPAM(PromptEchoOff, "Password: "); err != nil || r != "secret" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+	if c := NewTerminalConversation(); !c.useFd() {
+		t.Fatalf("useFd #unexpected result for NewTerminalConversation")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !unix
+
+package pam
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// readFileLine reads a line from f, without its line terminator. It reads a
+// byte at a time, so that nothing past the line is consumed. Reads can't be
+// interrupted on this platform. The caller is responsible of wiping the
+// line.
+func readFileLine(f *os.File) ([]byte, error) {
+	line := make([]byte, 0, 128)
+	var b [1]byte
+	for {
+		n, err := f.Read(b[:])
+		if n == 0 && err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return trimCR(line), nil
+			}
+			wipeBytes(line)
+			return nil, err
+		}
+		if n == 0 {
+			continue
+		}
+		if b[0] == '\n' {
+			return trimCR(line), nil
+		}
+		line = appendByte(line, b[0])
+	}
+}
+
+// readTerminalPassword reads a line from the terminal fd with echo disabled.
+func readTerminalPassword(fd int) ([]byte, error) {
+	return term.ReadPassword(fd)
+}
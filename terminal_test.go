@@ -0,0 +1,172 @@
+package pam
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalConversation(t *testing.T) {
+	t.Parallel()
+	var out, errOut bytes.Buffer
+	c := &TerminalConversation{
+		In:  strings.NewReader("test\nsecret\r\nlast"),
+		Out: &out,
+		Err: &errOut,
+		Fd:  -1,
+	}
+
+	responses := []struct {
+		style    Style
+		msg      string
+		expected string
+	}{
+		{PromptEchoOn, "login: ", "test"},
+		{PromptEchoOff, "Password: ", "secret"},
+		{TextInfo, "info", ""},
+		{ErrorMsg, "error\n", ""},
+		{PromptEchoOn, "Code: ", "last"},
+	}
+	for _, r := range responses {
+		resp, err := c.RespondPAM(r.style, r.msg)
+		if err != nil {
+			t.Fatalf("respond #error: %v", err)
+		}
+		if resp != r.expected {
+			t.Fatalf("respond #unexpected response for %q: %q", r.msg, resp)
+		}
+	}
+	if out.String() != "login: Password: info\nCode: " {
+		t.Fatalf("output #unexpected: %q", out.String())
+	}
+	if errOut.String() != "error\n" {
+		t.Fatalf("error output #unexpected: %q", errOut.String())
+	}
+
+	if _, err := c.RespondPAM(PromptEchoOn, "login: "); err == nil {
+		t.Fatalf("respond #expected an error")
+	}
+	if _, err := c.RespondPAM(BinaryPrompt, ""); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestTerminalConversation_Secret(t *testing.T) {
+	t.Parallel()
+	var out bytes.Buffer
+	c := &TerminalConversation{
+		In:  strings.NewReader("test\nsecret\n"),
+		Out: &out,
+		Err: &out,
+		Fd:  -1,
+	}
+	for _, expected := range []string{"test", "secret"} {
+		s, err := c.RespondPAMSecret(PromptEchoOff, "Password: ")
+		if err != nil {
+			t.Fatalf("respond #error: %v", err)
+		}
+		if string(s) != expected {
+			t.Fatalf("respond #unexpected response: %q", []byte(s))
+		}
+		s.Wipe()
+	}
+	if _, err := c.RespondPAMSecret(TextInfo, "info"); !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
+
+func TestTerminalConversation_Silent(t *testing.T) {
+	t.Parallel()
+	var out, errOut bytes.Buffer
+	c := &TerminalConversation{
+		In:     strings.NewReader(""),
+		Out:    &out,
+		Err:    &errOut,
+		Fd:     -1,
+		Silent: true,
+	}
+	for _, s := range []Style{TextInfo, ErrorMsg} {
+		if _, err := c.RespondPAM(s, "message"); err != nil {
+			t.Fatalf("respond #error: %v", err)
+		}
+	}
+	if out.Len() != 0 || errOut.Len() != 0 {
+		t.Fatalf("output #unexpected: %q %q", out.String(), errOut.String())
+	}
+}
+
+func TestTerminalConversation_Interrupt(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("reads can't be interrupted on this platform")
+	}
+	// Keep the process from being terminated by the interrupts.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe #error: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	var out bytes.Buffer
+	c := &TerminalConversation{In: r, Out: &out, Err: &out, Fd: -1}
+
+	done := make(chan error)
+	go func() {
+		_, err := c.RespondPAM(PromptEchoOff, "Password: ")
+		done <- err
+	}()
+	p, _ := os.FindProcess(os.Getpid())
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for err = nil; err == nil; {
+		select {
+		case err = <-done:
+		case <-ticker.C:
+			_ = p.Signal(os.Interrupt)
+		}
+	}
+	if !errors.Is(err, ErrConv) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+
+	// The interrupted read must not consume the next line.
+	if _, err := w.Write([]byte("next\n")); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	if r, err := c.RespondPAM(PromptEchoOn, "login: "); err != nil || r != "next" {
+		t.Fatalf("respond #unexpected result: %q, %v", r, err)
+	}
+}
+
+func TestTerminalConversation_File(t *testing.T) {
+	t.Parallel()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe #error: %v", err)
+	}
+	defer r.Close()
+	if _, err := w.Write([]byte("test\r\nsecret\nlast")); err != nil {
+		t.Fatalf("write #error: %v", err)
+	}
+	w.Close()
+
+	var out bytes.Buffer
+	c := &TerminalConversation{In: r, Out: &out, Err: &out, Fd: -1}
+	for _, expected := range []string{"test", "secret", "last"} {
+		if r, err := c.RespondPAM(PromptEchoOn, "login: "); err != nil || r != expected {
+			t.Fatalf("respond #unexpected result: %q, %v", r, err)
+		}
+	}
+	if _, err := c.RespondPAM(PromptEchoOn, "login: "); !errors.Is(err, io.EOF) {
+		t.Fatalf("respond #unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build unix
+
+package pam
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// readFileLine reads a line from f, without its line terminator. It reads a
+// byte at a time, so that nothing past the line is consumed, and fails with
+// errInterrupted if the process is interrupted while waiting for it. In both
+// cases nothing is left reading from f when it returns. The caller is
+// responsible of wiping the line.
+func readFileLine(f *os.File) ([]byte, error) {
+	defer runtime.KeepAlive(f)
+	return readFdLine(int(f.Fd()))
+}
+
+// readFdLine reads a line from fd, as readFileLine does.
+func readFdLine(fd int) ([]byte, error) {
+	// The signal is forwarded to a pipe, so that it can be polled along
+	// with the file.
+	var p [2]int
+	if err := unix.Pipe(p[:]); err != nil {
+		return nil, err
+	}
+	defer unix.Close(p[0])
+	defer unix.Close(p[1])
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	stop := make(chan struct{})
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		select {
+		case <-interrupt:
+			_, _ = unix.Write(p[1], []byte{0})
+		case <-stop:
+		}
+	}()
+	defer func() {
+		signal.Stop(interrupt)
+		close(stop)
+		<-forwarded
+	}()
+
+	line := make([]byte, 0, 128)
+	var b [1]byte
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(fd), Events: unix.POLLIN},
+			{Fd: int32(p[0]), Events: unix.POLLIN},
+		}
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			wipeBytes(line)
+			return nil, err
+		}
+		if fds[1].Revents != 0 {
+			wipeBytes(line)
+			return nil, errInterrupted
+		}
+		if fds[0].Revents == 0 {
+			continue
+		}
+		n, err := unix.Read(fd, b[:])
+		switch {
+		case errors.Is(err, unix.EINTR), errors.Is(err, unix.EAGAIN):
+			continue
+		case err != nil:
+			wipeBytes(line)
+			return nil, err
+		case n == 0:
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+			return trimCR(line), nil
+		case b[0] == '\n':
+			return trimCR(line), nil
+		}
+		line = appendByte(line, b[0])
+	}
+}
+
+// readTerminalPassword reads a line from the terminal fd with echo disabled,
+// as readFileLine does.
+func readTerminalPassword(fd int) ([]byte, error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	state := *termios
+	termios.Lflag &^= unix.ECHO
+	termios.Lflag |= unix.ICANON | unix.ISIG
+	termios.Iflag |= unix.ICRNL
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, termios); err != nil {
+		return nil, err
+	}
+	defer func() { _ = unix.IoctlSetTermios(fd, ioctlSetTermios, &state) }()
+
+	return readFdLine(fd)
+}
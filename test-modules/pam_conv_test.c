@@ -0,0 +1,203 @@
+/*
+ * Test module that performs a single conversation with the application.
+ *
+ * Each module argument adds a message to the conversation:
+ *
+ *   info=TEXT                 PAM_TEXT_INFO message
+ *   error=TEXT                PAM_ERROR_MSG message
+ *   echo_on=PROMPT:EXPECTED   PAM_PROMPT_ECHO_ON, expecting EXPECTED as reply
+ *   echo_off=PROMPT:EXPECTED  PAM_PROMPT_ECHO_OFF, expecting EXPECTED as reply
+ *   binary=CONTROL:DATA:REPLY_CONTROL:REPLY_DATA
+ *                             PAM_BINARY_PROMPT using the libpamc framing,
+ *                             expecting a reply with the given control and
+ *                             data
+ *
+ * The module returns the conversation error if the conversation fails,
+ * PAM_AUTH_ERR if a reply is not the expected one, or PAM_SUCCESS.
+ */
+
+#include <security/pam_appl.h>
+#include <security/pam_modules.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+#ifndef PAM_BINARY_PROMPT
+#define PAM_BINARY_PROMPT 7
+#endif
+
+#define HEADER_SIZE 5
+
+struct message {
+	struct pam_message msg;
+	char *text;
+	char *expected;
+	unsigned char reply_control;
+};
+
+static uint32_t binary_length(const unsigned char *data)
+{
+	return ((uint32_t)data[0] << 24) | ((uint32_t)data[1] << 16) |
+	       ((uint32_t)data[2] << 8) | (uint32_t)data[3];
+}
+
+static int parse_binary(struct message *m, const char *arg)
+{
+	char *copy = strdup(arg);
+	char *fields[4] = { NULL };
+	char *p = copy;
+	size_t data_len;
+	unsigned char *data;
+	int i;
+
+	if (!copy)
+		return -1;
+	for (i = 0; i < 4; ++i) {
+		fields[i] = p;
+		if (!p)
+			break;
+		p = strchr(p, ':');
+		if (p)
+			*p++ = '\0';
+	}
+	data_len = fields[3] ? strlen(fields[1]) : 0;
+	data = fields[3] ? malloc(HEADER_SIZE + data_len) : NULL;
+	if (!data) {
+		free(copy);
+		return -1;
+	}
+	data[0] = ((HEADER_SIZE + data_len) >> 24) & 0xff;
+	data[1] = ((HEADER_SIZE + data_len) >> 16) & 0xff;
+	data[2] = ((HEADER_SIZE + data_len) >> 8) & 0xff;
+	data[3] = (HEADER_SIZE + data_len) & 0xff;
+	data[4] = (unsigned char)strtoul(fields[0], NULL, 0);
+	memcpy(data + HEADER_SIZE, fields[1], data_len);
+
+	m->text = (char *)data;
+	m->reply_control = (unsigned char)strtoul(fields[2], NULL, 0);
+	m->expected = strdup(fields[3]);
+	free(copy);
+	return 0;
+}
+
+static int parse_prompt(struct message *m, const char *arg)
+{
+	const char *sep = strrchr(arg, ':');
+
+	if (!sep)
+		return -1;
+	m->text = strndup(arg, sep - arg);
+	m->expected = strdup(sep + 1);
+	return 0;
+}
+
+static int parse_message(struct message *m, const char *arg)
+{
+	memset(m, 0, sizeof *m);
+	if (strncmp(arg, "info=", 5) == 0) {
+		m->msg.msg_style = PAM_TEXT_INFO;
+		m->text = strdup(arg + 5);
+		return 0;
+	}
+	if (strncmp(arg, "error=", 6) == 0) {
+		m->msg.msg_style = PAM_ERROR_MSG;
+		m->text = strdup(arg + 6);
+		return 0;
+	}
+	if (strncmp(arg, "echo_on=", 8) == 0) {
+		m->msg.msg_style = PAM_PROMPT_ECHO_ON;
+		return parse_prompt(m, arg + 8);
+	}
+	if (strncmp(arg, "echo_off=", 9) == 0) {
+		m->msg.msg_style = PAM_PROMPT_ECHO_OFF;
+		return parse_prompt(m, arg + 9);
+	}
+	if (strncmp(arg, "binary=", 7) == 0) {
+		m->msg.msg_style = PAM_BINARY_PROMPT;
+		return parse_binary(m, arg + 7);
+	}
+	return -1;
+}
+
+static int check_reply(const struct message *m, const char *resp)
+{
+	const unsigned char *data = (const unsigned char *)resp;
+	size_t expected_len;
+
+	switch (m->msg.msg_style) {
+	case PAM_PROMPT_ECHO_ON:
+	case PAM_PROMPT_ECHO_OFF:
+		return resp && strcmp(resp, m->expected) == 0;
+	case PAM_BINARY_PROMPT:
+		expected_len = strlen(m->expected);
+		return data && binary_length(data) == HEADER_SIZE + expected_len &&
+		       data[4] == m->reply_control &&
+		       memcmp(data + HEADER_SIZE, m->expected, expected_len) == 0;
+	}
+	return 1;
+}
+
+static int converse(pam_handle_t *pamh, int argc, const char **argv)
+{
+	const struct pam_conv *conv;
+	const struct pam_message **msgs = NULL;
+	struct pam_response *resp = NULL;
+	struct message *messages;
+	int ret;
+	int i;
+
+	ret = pam_get_item(pamh, PAM_CONV, (const void **)&conv);
+	if (ret != PAM_SUCCESS)
+		return ret;
+	if (argc == 0)
+		return PAM_SUCCESS;
+
+	messages = calloc(argc, sizeof *messages);
+	msgs = calloc(argc, sizeof *msgs);
+	if (!messages || !msgs) {
+		ret = PAM_BUF_ERR;
+		goto out;
+	}
+	for (i = 0; i < argc; ++i) {
+		if (parse_message(&messages[i], argv[i]) != 0) {
+			ret = PAM_SERVICE_ERR;
+			goto out;
+		}
+		messages[i].msg.msg = messages[i].text;
+		msgs[i] = &messages[i].msg;
+	}
+
+	ret = conv->conv(argc, msgs, &resp, conv->appdata_ptr);
+	if (ret != PAM_SUCCESS)
+		goto out;
+	if (!resp) {
+		ret = PAM_CONV_ERR;
+		goto out;
+	}
+	for (i = 0; i < argc; ++i) {
+		if (!check_reply(&messages[i], resp[i].resp))
+			ret = PAM_AUTH_ERR;
+	}
+
+out:
+	for (i = 0; resp && i < argc; ++i)
+		free(resp[i].resp);
+	free(resp);
+	for (i = 0; messages && i < argc; ++i) {
+		free(messages[i].text);
+		free(messages[i].expected);
+	}
+	free(messages);
+	free(msgs);
+	return ret;
+}
+
+PAM_EXTERN int pam_sm_authenticate(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return converse(pamh, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_setcred(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return PAM_SUCCESS;
+}
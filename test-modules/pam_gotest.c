@@ -0,0 +1,318 @@
+/*
+ * Test module whose behavior is driven by its arguments, so that any PAM
+ * flow can be tested without relying on the state of the system.
+ *
+ * Return values:
+ *
+ *   auth=RET, setcred=RET, acct=RET, open_session=RET, close_session=RET,
+ *   prechauthtok=RET, chauthtok=RET
+ *
+ *     RET is the return value of the function, either a number or a name
+ *     such as "success", "auth_err" or "new_authtok_reqd" (see pam_debug).
+ *
+ * Actions, performed in order by pam_sm_authenticate, pam_sm_acct_mgmt,
+ * pam_sm_open_session and pam_sm_chauthtok (during PAM_UPDATE_AUTHTOK only):
+ *
+ *   info=TEXT, error=TEXT        send a PAM_TEXT_INFO or PAM_ERROR_MSG
+ *   echo_on=TEXT, echo_off=TEXT  send a prompt
+ *   expect=VALUE                 fail with PAM_AUTH_ERR unless the response
+ *                                to the last prompt is VALUE
+ *   authtok=VALUE                get PAM_AUTHTOK with pam_get_authtok and
+ *                                fail with PAM_AUTH_ERR unless it's VALUE
+ *   item=NAME:VALUE              set an item (user, tty, rhost, ruser,
+ *                                authtok, oldauthtok, user_prompt, xdisplay)
+ *   expect_item=NAME:VALUE       fail with PAM_AUTH_ERR unless the item is
+ *                                VALUE, an empty VALUE matches unset items
+ *   env=NAME=VALUE               set a PAM environment variable
+ *
+ * pam_sm_chauthtok during PAM_PRELIM_CHECK only handles:
+ *
+ *   oldauthtok=VALUE             get PAM_OLDAUTHTOK with pam_get_authtok and
+ *                                fail with PAM_AUTH_ERR unless it's VALUE
+ *
+ * If the GOTEST_SCRIPT PAM environment variable is set, the file it points
+ * to is read and each of its lines is handled as an additional argument.
+ */
+
+#include <security/pam_appl.h>
+#include <security/pam_modules.h>
+#include <security/pam_ext.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#define MAX_ARGS 64
+
+struct args {
+	int argc;
+	const char *argv[MAX_ARGS];
+	char *script[MAX_ARGS];
+	int script_argc;
+};
+
+static const struct {
+	const char *name;
+	int value;
+} returns[] = {
+	{ "success", PAM_SUCCESS },
+	{ "open_err", PAM_OPEN_ERR },
+	{ "symbol_err", PAM_SYMBOL_ERR },
+	{ "service_err", PAM_SERVICE_ERR },
+	{ "system_err", PAM_SYSTEM_ERR },
+	{ "buf_err", PAM_BUF_ERR },
+	{ "perm_denied", PAM_PERM_DENIED },
+	{ "auth_err", PAM_AUTH_ERR },
+	{ "cred_insufficient", PAM_CRED_INSUFFICIENT },
+	{ "authinfo_unavail", PAM_AUTHINFO_UNAVAIL },
+	{ "user_unknown", PAM_USER_UNKNOWN },
+	{ "maxtries", PAM_MAXTRIES },
+	{ "new_authtok_reqd", PAM_NEW_AUTHTOK_REQD },
+	{ "acct_expired", PAM_ACCT_EXPIRED },
+	{ "session_err", PAM_SESSION_ERR },
+	{ "cred_unavail", PAM_CRED_UNAVAIL },
+	{ "cred_expired", PAM_CRED_EXPIRED },
+	{ "cred_err", PAM_CRED_ERR },
+	{ "no_module_data", PAM_NO_MODULE_DATA },
+	{ "conv_err", PAM_CONV_ERR },
+	{ "authtok_err", PAM_AUTHTOK_ERR },
+	{ "authtok_recover_err", PAM_AUTHTOK_RECOVERY_ERR },
+	{ "authtok_lock_busy", PAM_AUTHTOK_LOCK_BUSY },
+	{ "authtok_disable_aging", PAM_AUTHTOK_DISABLE_AGING },
+	{ "try_again", PAM_TRY_AGAIN },
+	{ "ignore", PAM_IGNORE },
+	{ "abort", PAM_ABORT },
+	{ "authtok_expired", PAM_AUTHTOK_EXPIRED },
+	{ "module_unknown", PAM_MODULE_UNKNOWN },
+	{ "bad_item", PAM_BAD_ITEM },
+	{ "conv_again", PAM_CONV_AGAIN },
+	{ "incomplete", PAM_INCOMPLETE },
+};
+
+static const struct {
+	const char *name;
+	int value;
+} items[] = {
+	{ "service", PAM_SERVICE },
+	{ "user", PAM_USER },
+	{ "tty", PAM_TTY },
+	{ "rhost", PAM_RHOST },
+	{ "authtok", PAM_AUTHTOK },
+	{ "oldauthtok", PAM_OLDAUTHTOK },
+	{ "ruser", PAM_RUSER },
+	{ "user_prompt", PAM_USER_PROMPT },
+	{ "xdisplay", PAM_XDISPLAY },
+	{ "authtok_type", PAM_AUTHTOK_TYPE },
+};
+
+static int parse_return(const char *value)
+{
+	char *end;
+	long n;
+
+	for (size_t i = 0; i < sizeof returns / sizeof *returns; ++i) {
+		if (strcmp(value, returns[i].name) == 0)
+			return returns[i].value;
+	}
+	n = strtol(value, &end, 0);
+	if (*value && !*end)
+		return (int)n;
+	return PAM_SERVICE_ERR;
+}
+
+static int parse_item(const char *value, const char **item_value)
+{
+	const char *sep = strchr(value, ':');
+
+	if (!sep)
+		return -1;
+	*item_value = sep + 1;
+	for (size_t i = 0; i < sizeof items / sizeof *items; ++i) {
+		if (strncmp(value, items[i].name, sep - value) == 0 &&
+		    strlen(items[i].name) == (size_t)(sep - value))
+			return items[i].value;
+	}
+	return -1;
+}
+
+static void load_args(pam_handle_t *pamh, struct args *args, int argc, const char **argv)
+{
+	const char *path = pam_getenv(pamh, "GOTEST_SCRIPT");
+	char *line = NULL;
+	size_t size = 0;
+	ssize_t len;
+	FILE *f;
+
+	memset(args, 0, sizeof *args);
+	for (int i = 0; i < argc && args->argc < MAX_ARGS; ++i)
+		args->argv[args->argc++] = argv[i];
+
+	if (!path || !*path)
+		return;
+	f = fopen(path, "r");
+	if (!f)
+		return;
+	while (args->argc < MAX_ARGS && (len = getline(&line, &size, f)) >= 0) {
+		if (len > 0 && line[len - 1] == '\n')
+			line[--len] = '\0';
+		if (len == 0)
+			continue;
+		args->script[args->script_argc] = strdup(line);
+		args->argv[args->argc++] = args->script[args->script_argc++];
+	}
+	free(line);
+	fclose(f);
+}
+
+static void free_args(struct args *args)
+{
+	for (int i = 0; i < args->script_argc; ++i)
+		free(args->script[i]);
+}
+
+/* get_return returns the last value of the NAME=RET argument. */
+static int get_return(const struct args *args, const char *name)
+{
+	size_t name_len = strlen(name);
+	int ret = PAM_SUCCESS;
+
+	for (int i = 0; i < args->argc; ++i) {
+		if (strncmp(args->argv[i], name, name_len) == 0 &&
+		    args->argv[i][name_len] == '=')
+			ret = parse_return(args->argv[i] + name_len + 1);
+	}
+	return ret;
+}
+
+static int check_authtok(pam_handle_t *pamh, int item, const char *expected)
+{
+	const char *authtok = NULL;
+	int ret = pam_get_authtok(pamh, item, &authtok, NULL);
+
+	if (ret != PAM_SUCCESS)
+		return ret;
+	if (!authtok || strcmp(authtok, expected) != 0)
+		return PAM_AUTH_ERR;
+	return PAM_SUCCESS;
+}
+
+static int prompt(pam_handle_t *pamh, int style, const char *text, char **response)
+{
+	free(*response);
+	*response = NULL;
+	return pam_prompt(pamh, style, response, "%s", text);
+}
+
+static int run_actions(pam_handle_t *pamh, const struct args *args)
+{
+	char *response = NULL;
+	int ret = PAM_SUCCESS;
+
+	for (int i = 0; i < args->argc && ret == PAM_SUCCESS; ++i) {
+		const char *arg = args->argv[i];
+		const char *value = strchr(arg, '=');
+		const char *item_value;
+		const void *current;
+		size_t name_len;
+		int item;
+
+		if (!value)
+			continue;
+		name_len = value - arg;
+		value++;
+
+#define IS(name) (name_len == strlen(name) && strncmp(arg, name, name_len) == 0)
+		if (IS("info")) {
+			ret = prompt(pamh, PAM_TEXT_INFO, value, &response);
+		} else if (IS("error")) {
+			ret = prompt(pamh, PAM_ERROR_MSG, value, &response);
+		} else if (IS("echo_on")) {
+			ret = prompt(pamh, PAM_PROMPT_ECHO_ON, value, &response);
+		} else if (IS("echo_off")) {
+			ret = prompt(pamh, PAM_PROMPT_ECHO_OFF, value, &response);
+		} else if (IS("expect")) {
+			if (!response || strcmp(response, value) != 0)
+				ret = PAM_AUTH_ERR;
+		} else if (IS("authtok")) {
+			ret = check_authtok(pamh, PAM_AUTHTOK, value);
+		} else if (IS("item")) {
+			item = parse_item(value, &item_value);
+			ret = item < 0 ? PAM_SERVICE_ERR : pam_set_item(pamh, item, item_value);
+		} else if (IS("expect_item")) {
+			item = parse_item(value, &item_value);
+			if (item < 0) {
+				ret = PAM_SERVICE_ERR;
+				continue;
+			}
+			ret = pam_get_item(pamh, item, &current);
+			if (ret != PAM_SUCCESS)
+				continue;
+			if (*item_value == '\0' ? current != NULL :
+			    (!current || strcmp(current, item_value) != 0))
+				ret = PAM_AUTH_ERR;
+		} else if (IS("env")) {
+			ret = pam_putenv(pamh, value);
+		}
+#undef IS
+	}
+
+	free(response);
+	return ret;
+}
+
+static int handle(pam_handle_t *pamh, const char *name, int actions, int argc, const char **argv)
+{
+	struct args args;
+	int ret = PAM_SUCCESS;
+
+	load_args(pamh, &args, argc, argv);
+	if (actions)
+		ret = run_actions(pamh, &args);
+	if (ret == PAM_SUCCESS)
+		ret = get_return(&args, name);
+	free_args(&args);
+	return ret;
+}
+
+PAM_EXTERN int pam_sm_authenticate(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return handle(pamh, "auth", 1, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_setcred(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return handle(pamh, "setcred", 0, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_acct_mgmt(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return handle(pamh, "acct", 1, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_open_session(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return handle(pamh, "open_session", 1, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_close_session(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	return handle(pamh, "close_session", 0, argc, argv);
+}
+
+PAM_EXTERN int pam_sm_chauthtok(pam_handle_t *pamh, int flags, int argc, const char **argv)
+{
+	struct args args;
+	int ret = PAM_SUCCESS;
+
+	if (flags & PAM_UPDATE_AUTHTOK)
+		return handle(pamh, "chauthtok", 1, argc, argv);
+
+	load_args(pamh, &args, argc, argv);
+	for (int i = 0; i < args.argc && ret == PAM_SUCCESS; ++i) {
+		if (strncmp(args.argv[i], "oldauthtok=", 11) == 0)
+			ret = check_authtok(pamh, PAM_OLDAUTHTOK, args.argv[i] + 11);
+	}
+	if (ret == PAM_SUCCESS)
+		ret = get_return(&args, "prechauthtok");
+	free_args(&args);
+	return ret;
+}
@@ -0,0 +1,88 @@
+package pam
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testModules struct {
+	mu      sync.Mutex
+	dir     string
+	modules map[string]string
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testModules.dir != "" {
+		_ = os.RemoveAll(testModules.dir)
+	}
+	os.Exit(code)
+}
+
+// buildTestModule compiles the test module from the test-modules directory
+// and returns the path of the shared object. Modules are built once per
+// test run.
+func buildTestModule(t *testing.T, name string) string {
+	t.Helper()
+
+	testModules.mu.Lock()
+	defer testModules.mu.Unlock()
+	if module, ok := testModules.modules[name]; ok {
+		return module
+	}
+	if testModules.dir == "" {
+		dir, err := os.MkdirTemp("", "go-pam-test-modules-")
+		if err != nil {
+			t.Fatalf("can't create modules directory: %v", err)
+		}
+		testModules.dir = dir
+		testModules.modules = make(map[string]string)
+	}
+
+	out, err := exec.Command("go", "env", "CC", "CGO_CFLAGS", "CGO_LDFLAGS").Output()
+	if err != nil {
+		t.Fatalf("can't get the C compiler: %v", err)
+	}
+	env := strings.Split(string(out), "\n")
+	args := strings.Fields(env[0])
+	args = append(args, strings.Fields(env[1])...)
+	module := filepath.Join(testModules.dir, name+".so")
+	args = append(args, "-Wall", "-shared", "-fPIC", "-o", module,
+		filepath.Join("test-modules", name+".c"))
+	args = append(args, strings.Fields(env[2])...)
+	args = append(args, "-lpam")
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("can't build module %v: %v\n%s", name, err, out)
+	}
+	testModules.modules[name] = module
+	return module
+}
+
+// writeTestService creates a service in a temporary configuration directory
+// and returns the directory path.
+func writeTestService(t *testing.T, serviceName string, lines ...string) string {
+	t.Helper()
+
+	servicePath := t.TempDir()
+	serviceFile := filepath.Join(servicePath, serviceName)
+	contents := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(serviceFile, []byte(contents), 0600); err != nil {
+		t.Fatalf("can't create service file %v: %v", serviceFile, err)
+	}
+	return servicePath
+}
+
+// moduleArg quotes a module argument containing spaces.
+func moduleArg(arg string) string {
+	if !strings.ContainsAny(arg, " \t") {
+		return arg
+	}
+	return fmt.Sprintf("[%s]", strings.ReplaceAll(arg, "]", "\\]"))
+}
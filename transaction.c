@@ -1,3 +1,5 @@
+//go:build cgo
+
 #include "_cgo_export.h"
 #include <security/pam_appl.h>
 #include <stdint.h>
@@ -14,33 +16,52 @@ int cb_pam_conv(int num_msg, PAM_CONST struct pam_message **msg, struct pam_resp
 	if (num_msg <= 0 || num_msg > PAM_MAX_NUM_MSG)
 		return PAM_CONV_ERR;
 
+	/* Binary responses are not NUL terminated, so track their sizes. */
+	size_t sizes[PAM_MAX_NUM_MSG] = { 0 };
+
 	*resp = calloc(num_msg, sizeof **resp);
 	if (!*resp)
 		return PAM_BUF_ERR;
 
+	/* Batch handlers receive all the messages before answering them. */
+	int styles[PAM_MAX_NUM_MSG];
+	char *texts[PAM_MAX_NUM_MSG];
+	for (size_t i = 0; i < num_msg; ++i) {
+		styles[i] = msg[i]->msg_style;
+		texts[i] = (char *)msg[i]->msg;
+	}
+	if (cbPAMConvBatch(num_msg, styles, texts, (uintptr_t)appdata_ptr) != PAM_SUCCESS)
+		goto error;
+
 	for (size_t i = 0; i < num_msg; ++i) {
 		struct cbPAMConv_return result = cbPAMConv(msg[i]->msg_style, (char *)msg[i]->msg, (uintptr_t)appdata_ptr);
 		if (result.r1 != PAM_SUCCESS)
 			goto error;
 
 		(*resp)[i].resp = result.r0;
+		sizes[i] = result.r2;
 	}
 
 	return PAM_SUCCESS;
 error:
 	for (size_t i = 0; i < num_msg; ++i) {
 		if ((*resp)[i].resp) {
-			memset((*resp)[i].resp, 0, strlen((*resp)[i].resp));
+			memset((*resp)[i].resp, 0, sizes[i]);
 			free((*resp)[i].resp);
 		}
 	}
 
-	memset(*resp, 0, num_msg * sizeof *resp);
+	memset(*resp, 0, num_msg * sizeof **resp);
 	free(*resp);
 	*resp = NULL;
 	return PAM_CONV_ERR;
 }
 
+void cb_pam_fail_delay(int retval, unsigned usec_delay, void *appdata_ptr)
+{
+	cbPAMFailDelay(retval, usec_delay, (uintptr_t)appdata_ptr);
+}
+
 void init_pam_conv(struct pam_conv *conv, uintptr_t appdata)
 {
 	conv->conv = cb_pam_conv;
@@ -1,11 +1,11 @@
-// Package pam provides a wrapper for the PAM application API.
 package pam
 
 /*
 #cgo CFLAGS: -Wall -Wno-unused-variable -std=c99
-#cgo LDFLAGS: -ldl -lpam
+#cgo !pam_dlopen LDFLAGS: -ldl -lpam
+#cgo pam_dlopen CFLAGS: -I${SRCDIR}/dlopen/include -pthread
+#cgo pam_dlopen LDFLAGS: -ldl -pthread
 
-#include <dlfcn.h>
 #include <security/pam_appl.h>
 #include <stdlib.h>
 #include <stdint.h>
@@ -19,6 +19,7 @@ package pam
 #endif
 
 void init_pam_conv(struct pam_conv *conv, uintptr_t);
+void cb_pam_fail_delay(int retval, unsigned usec_delay, void *appdata_ptr);
 
 typedef int (*pam_start_confdir_fn)(const char *service_name, const char *user, const struct pam_conv *pam_conversation, const char *confdir, pam_handle_t **pamh);
 
@@ -32,15 +33,13 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 // success indicates a successful function return.
 const success = C.PAM_SUCCESS
 
-// Style is the type of message that the conversation handler should display.
-type Style int
-
 // Coversation handler style types.
 const (
 	// PromptEchoOff indicates the conversation handler should obtain a
@@ -60,74 +59,143 @@ const (
 	BinaryPrompt Style = C.PAM_BINARY_PROMPT
 )
 
-// ConversationHandler is an interface for objects that can be used as
-// conversation callbacks during PAM authentication.
-type ConversationHandler interface {
-	// RespondPAM receives a message style and a message string. If the
-	// message Style is PromptEchoOff or PromptEchoOn then the function
-	// should return a response string.
-	RespondPAM(Style, string) (string, error)
-}
-
-// BinaryPointer exposes the type used for the data in a binary conversation.
-// It represents a pointer to data that is produced by the module and must be
-// parsed depending on the protocol in use.
-type BinaryPointer unsafe.Pointer
-
-// BinaryConversationHandler is an interface for objects that can be used as
-// conversation callbacks during PAM authentication if binary protocol is going
-// to be supported.
-type BinaryConversationHandler interface {
-	ConversationHandler
-	// RespondPAMBinary receives a pointer to the binary message. It's up to
-	// the receiver to parse it according to the protocol specifications.
-	// The function can return a byte array that will passed as pointer back
-	// to the module.
-	RespondPAMBinary(BinaryPointer) ([]byte, error)
+// cbPAMConv is a wrapper for the conversation callback function. It returns
+// the response, the conversation status and the size of the response, so
+// that it can be wiped.
+//
+//export cbPAMConv
+func cbPAMConv(s C.int, msg *C.char, c C.uintptr_t) (*C.char, C.int, C.size_t) {
+	cb := cgo.Handle(c).Value().(*callbacks)
+	style := Style(s)
+	var run *conversationRun
+	// The hooks of the batched messages were invoked by cbPAMConvBatch.
+	if len(cb.batch) == 0 && cb.hasHooks() {
+		var text string
+		if style != BinaryPrompt {
+			text = C.GoString(msg)
+		}
+		run = cb.startConversation(style, text)
+	}
+	r, status, size := cb.respond(style, msg)
+	cb.endConversation(run, Error(status))
+	return r, status, size
 }
 
-// ConversationFunc is an adapter to allow the use of ordinary functions as
-// conversation callbacks.
-type ConversationFunc func(Style, string) (string, error)
-
-// RespondPAM is a conversation callback adapter.
-func (f ConversationFunc) RespondPAM(s Style, msg string) (string, error) {
-	return f(s, msg)
+// cbPAMConvBatch passes all the messages of a conversation to the handler
+// at once, if it's a BatchConversationHandler, and stores the responses that
+// cbPAMConv returns then. The hooks are invoked for each of the messages
+// around the handler call.
+//
+//export cbPAMConvBatch
+func cbPAMConvBatch(n C.int, styles *C.int, msgs **C.char, c C.uintptr_t) C.int {
+	cb := cgo.Handle(c).Value().(*callbacks)
+	cb.batch = nil
+	h, ok := cb.handler.(BatchConversationHandler)
+	if !ok {
+		return success
+	}
+	cStyles := unsafe.Slice(styles, int(n))
+	cMsgs := unsafe.Slice(msgs, int(n))
+	messages := make([]Message, 0, int(n))
+	for i, s := range cStyles {
+		style := Style(s)
+		if style == BinaryPrompt {
+			// Binary messages can't be batched, handle them one by one.
+			return success
+		}
+		messages = append(messages, Message{Style: style, Msg: C.GoString(cMsgs[i])})
+	}
+	var runs []*conversationRun
+	if cb.hasHooks() {
+		for _, m := range messages {
+			runs = append(runs, cb.startConversation(m.Style, m.Msg))
+		}
+	}
+	responses, err := h.RespondPAMBatch(messages)
+	status := Error(success)
+	if err != nil || len(responses) != len(messages) {
+		status = ErrConv
+	}
+	for _, run := range runs {
+		cb.endConversation(run, status)
+	}
+	if status != success {
+		return C.int(status)
+	}
+	cb.batch = responses
+	return success
 }
 
-// cbPAMConv is a wrapper for the conversation callback function.
-//
-//export cbPAMConv
-func cbPAMConv(s C.int, msg *C.char, c C.uintptr_t) (*C.char, C.int) {
+// respond passes the message to the conversation handler, returning the
+// response as cbPAMConv does.
+func (cb *callbacks) respond(style Style, msg *C.char) (*C.char, C.int, C.size_t) {
+	if len(cb.batch) > 0 {
+		r := cb.batch[0]
+		cb.batch = cb.batch[1:]
+		return C.CString(r), success, C.size_t(len(r))
+	}
 	var r string
 	var err error
-	v := cgo.Handle(c).Value()
-	style := Style(s)
+	v := cb.handler
 	var handler ConversationHandler
 	switch cb := v.(type) {
 	case BinaryConversationHandler:
 		if style == BinaryPrompt {
 			bytes, err := cb.RespondPAMBinary(BinaryPointer(msg))
 			if err != nil {
-				return nil, C.int(ErrConv)
+				return nil, C.int(ErrConv), 0
 			}
-			return (*C.char)(C.CBytes(bytes)), success
+			return (*C.char)(C.CBytes(bytes)), success, C.size_t(len(bytes))
 		}
 		handler = cb
 	case ConversationHandler:
 		if style == BinaryPrompt {
-			return nil, C.int(ErrConv)
+			return nil, C.int(ErrConv), 0
 		}
 		handler = cb
 	}
 	if handler == nil {
-		return nil, C.int(ErrConv)
+		return nil, C.int(ErrConv), 0
+	}
+	if sh, ok := handler.(SecretConversationHandler); ok &&
+		(style == PromptEchoOff || style == PromptEchoOn) {
+		secret, err := sh.RespondPAMSecret(style, C.GoString(msg))
+		if err != nil {
+			return nil, C.int(ErrConv), 0
+		}
+		defer secret.Wipe()
+		r := cSecret(secret)
+		if r == nil {
+			return nil, C.int(ErrBuf), 0
+		}
+		return r, success, C.size_t(len(secret))
 	}
 	r, err = handler.RespondPAM(style, C.GoString(msg))
 	if err != nil {
-		return nil, C.int(ErrConv)
+		return nil, C.int(ErrConv), 0
+	}
+	return C.CString(r), success, C.size_t(len(r))
+}
+
+// cbPAMFailDelay is a wrapper for the fail delay callback function.
+//
+//export cbPAMFailDelay
+func cbPAMFailDelay(status C.int, usecDelay C.uint, c C.uintptr_t) {
+	if fn := cgo.Handle(c).Value().(*callbacks).failDelay.Load(); fn != nil {
+		(*fn)(Error(status), time.Duration(usecDelay)*time.Microsecond)
+	}
+}
+
+// cSecret copies the secret into a newly allocated NUL terminated C string.
+func cSecret(s Secret) *C.char {
+	p := C.malloc(C.size_t(len(s) + 1))
+	if p == nil {
+		return nil
 	}
-	return C.CString(r), success
+	b := unsafe.Slice((*byte)(p), len(s)+1)
+	copy(b, s)
+	b[len(s)] = 0
+	return (*C.char)(p)
 }
 
 // Transaction is the application's handle for a PAM transaction.
@@ -135,6 +203,7 @@ type Transaction struct {
 	handle     *C.pam_handle_t
 	conv       *C.struct_pam_conv
 	lastStatus atomic.Int32
+	callbacks  *callbacks
 	c          cgo.Handle
 }
 
@@ -147,8 +216,10 @@ func (t *Transaction) End() error {
 	}
 
 	defer t.c.Delete()
-	return t.handlePamStatus(C.pam_end((*C.pam_handle_t)(handle),
+	err := t.handlePamStatus(C.pam_end((*C.pam_handle_t)(handle),
 		C.int(t.lastStatus.Load())))
+	t.callbacks.endTransaction()
+	return err
 }
 
 // handlePamStatus stores the last error returned by PAM and converts it to a
@@ -161,6 +232,16 @@ func (t *Transaction) handlePamStatus(cStatus C.int) error {
 	return nil
 }
 
+// StrError returns the message that PAM provides for the status, which may
+// be localized. Error messages are otherwise the ones returned by
+// Error.Error, that are always the same.
+func (t *Transaction) StrError(status Error) string {
+	if t.handle == nil {
+		return status.Error()
+	}
+	return C.GoString(C.pam_strerror(t.handle, C.int(status)))
+}
+
 // Start initiates a new PAM transaction. Service is treated identically to
 // how pam_start treats it internally.
 //
@@ -198,6 +279,9 @@ func StartFunc(service, user string, handler func(Style, string) (string, error)
 // you're absolutely sure that your stack is multi-thread friendly (normally it
 // is not!) and using a LockOSThread/UnlockOSThread pair.
 func StartConfDir(service, user string, handler ConversationHandler, confDir string) (*Transaction, error) {
+	if err := loadLibrary(); err != nil {
+		return nil, err
+	}
 	if !CheckPamHasStartConfdir() {
 		return nil, fmt.Errorf(
 			"%w: StartConfDir was used, but the pam version on the system is not recent enough",
@@ -208,6 +292,9 @@ func StartConfDir(service, user string, handler ConversationHandler, confDir str
 }
 
 func start(service, user string, handler ConversationHandler, confDir string) (*Transaction, error) {
+	if err := loadLibrary(); err != nil {
+		return nil, err
+	}
 	switch handler.(type) {
 	case BinaryConversationHandler:
 		if !CheckPamHasBinaryProtocol() {
@@ -215,9 +302,11 @@ func start(service, user string, handler ConversationHandler, confDir string) (*
 				ErrSystem)
 		}
 	}
+	cb := &callbacks{handler: handler}
 	t := &Transaction{
-		conv: &C.struct_pam_conv{},
-		c:    cgo.NewHandle(handler),
+		conv:      &C.struct_pam_conv{},
+		callbacks: cb,
+		c:         cgo.NewHandle(cb),
 	}
 
 	C.init_pam_conv(t.conv, C.uintptr_t(t.c))
@@ -243,9 +332,6 @@ func start(service, user string, handler ConversationHandler, confDir string) (*
 	return t, nil
 }
 
-// Item is a an PAM information type.
-type Item int
-
 // PAM Item types.
 const (
 	// Service is the name which identifies the PAM stack.
@@ -267,26 +353,67 @@ const (
 )
 
 // SetItem sets a PAM information item.
+//
+// Items that are not strings, or that only the modules are allowed to set,
+// such as Authtok and Oldauthtok, are rejected with ErrBadItem. The same
+// applies to values containing NUL bytes, as they can't be represented.
 func (t *Transaction) SetItem(i Item, item string) error {
+	if err := checkSetItem(i); err != nil {
+		return err
+	}
+	if strings.IndexByte(item, 0) >= 0 {
+		return fmt.Errorf("%w: item value contains a NUL byte", errBadItem)
+	}
 	cs := unsafe.Pointer(C.CString(item))
 	defer C.free(cs)
 	return t.handlePamStatus(C.pam_set_item(t.handle, C.int(i), cs))
 }
 
-// GetItem retrieves a PAM information item.
+// UnsetItem clears a PAM information item, so that it's not set anymore.
+//
+// The same restrictions of SetItem apply, and the Service item can't be
+// unset.
+func (t *Transaction) UnsetItem(i Item) error {
+	if err := checkSetItem(i); err != nil {
+		return err
+	}
+	if i == Service {
+		return fmt.Errorf("%w: item %d can't be unset", errBadItem, i)
+	}
+	return t.handlePamStatus(C.pam_set_item(t.handle, C.int(i), nil))
+}
+
+// GetItem retrieves a PAM information item. Items that are not set are
+// returned as an empty string, use LookupItem to tell them apart.
 func (t *Transaction) GetItem(i Item) (string, error) {
+	item, _, err := t.LookupItem(i)
+	return item, err
+}
+
+// LookupItem retrieves a PAM information item, reporting whether it's set.
+//
+// Items that are not strings are rejected with ErrBadItem.
+func (t *Transaction) LookupItem(i Item) (string, bool, error) {
+	if err := checkItem(i); err != nil {
+		return "", false, err
+	}
 	var s unsafe.Pointer
 	err := t.handlePamStatus(C.pam_get_item(t.handle, C.int(i), &s))
-	if err != nil {
-		return "", err
+	if err != nil || s == nil {
+		return "", false, err
 	}
-	return C.GoString((*C.char)(s)), nil
+	return C.GoString((*C.char)(s)), true, nil
 }
 
-// Flags are inputs to various PAM functions than be combined with a bitwise
-// or. Refer to the official PAM documentation for which flags are accepted
-// by which functions.
-type Flags int
+// peekItem returns the value of an item, without affecting the status of
+// the transaction.
+func (t *Transaction) peekItem(i Item) string {
+	var s unsafe.Pointer
+	if C.pam_get_item(t.handle, C.int(i), &s) != success || s == nil {
+		return ""
+	}
+	return C.GoString((*C.char)(s))
+}
 
 // PAM Flag types.
 const (
@@ -311,11 +438,19 @@ const (
 	ChangeExpiredAuthtok Flags = C.PAM_CHANGE_EXPIRED_AUTHTOK
 )
 
+// operation runs a PAM operation, invoking the hooks.
+func (t *Transaction) operation(name string, f Flags, fn func() C.int) error {
+	r := t.startOperation(name, f)
+	return t.endOperation(r, t.handlePamStatus(fn()))
+}
+
 // Authenticate is used to authenticate the user.
 //
 // Valid flags: Silent, DisallowNullAuthtok.
 func (t *Transaction) Authenticate(f Flags) error {
-	return t.handlePamStatus(C.pam_authenticate(t.handle, C.int(f)))
+	return t.operation("pam_authenticate", f, func() C.int {
+		return C.pam_authenticate(t.handle, C.int(f))
+	})
 }
 
 // SetCred is used to establish, maintain and delete the credentials of a
@@ -323,35 +458,45 @@ func (t *Transaction) Authenticate(f Flags) error {
 //
 // Valid flags: EstablishCred, DeleteCred, ReinitializeCred, RefreshCred.
 func (t *Transaction) SetCred(f Flags) error {
-	return t.handlePamStatus(C.pam_setcred(t.handle, C.int(f)))
+	return t.operation("pam_setcred", f, func() C.int {
+		return C.pam_setcred(t.handle, C.int(f))
+	})
 }
 
 // AcctMgmt is used to determine if the user's account is valid.
 //
 // Valid flags: Silent, DisallowNullAuthtok.
 func (t *Transaction) AcctMgmt(f Flags) error {
-	return t.handlePamStatus(C.pam_acct_mgmt(t.handle, C.int(f)))
+	return t.operation("pam_acct_mgmt", f, func() C.int {
+		return C.pam_acct_mgmt(t.handle, C.int(f))
+	})
 }
 
 // ChangeAuthTok is used to change the authentication token.
 //
 // Valid flags: Silent, ChangeExpiredAuthtok.
 func (t *Transaction) ChangeAuthTok(f Flags) error {
-	return t.handlePamStatus(C.pam_chauthtok(t.handle, C.int(f)))
+	return t.operation("pam_chauthtok", f, func() C.int {
+		return C.pam_chauthtok(t.handle, C.int(f))
+	})
 }
 
 // OpenSession sets up a user session for an authenticated user.
 //
 // Valid flags: Silent.
 func (t *Transaction) OpenSession(f Flags) error {
-	return t.handlePamStatus(C.pam_open_session(t.handle, C.int(f)))
+	return t.operation("pam_open_session", f, func() C.int {
+		return C.pam_open_session(t.handle, C.int(f))
+	})
 }
 
 // CloseSession closes a previously opened session.
 //
 // Valid flags: Silent.
 func (t *Transaction) CloseSession(f Flags) error {
-	return t.handlePamStatus(C.pam_close_session(t.handle, C.int(f)))
+	return t.operation("pam_close_session", f, func() C.int {
+		return C.pam_close_session(t.handle, C.int(f))
+	})
 }
 
 // PutEnv adds or changes the value of PAM environment variables.
@@ -406,7 +551,7 @@ var pamStartConfdirPtr C.pam_start_confdir_fn
 // CheckPamHasStartConfdir reports whether PAM supports pam_system_confdir.
 func CheckPamHasStartConfdir() bool {
 	once.Do(func() {
-		pamStartConfdirPtr = C.pam_start_confdir_fn(C.dlsym(C.RTLD_NEXT, C.CString("pam_start_confdir")))
+		pamStartConfdirPtr = C.pam_start_confdir_fn(lookupSymbol("pam_start_confdir"))
 	})
 	return pamStartConfdirPtr != nil
 }
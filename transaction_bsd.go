@@ -0,0 +1,103 @@
+//go:build freebsd || openpam
+
+package pam
+
+/*
+#include <security/pam_appl.h>
+#include <security/openpam.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import "unsafe"
+
+// PAM Item types.
+const (
+	// Repository is the user account repository, it isn't a string.
+	Repository Item = C.PAM_REPOSITORY
+	// AuthtokPrompt is the string used to prompt for the authentication
+	// token.
+	AuthtokPrompt Item = C.PAM_AUTHTOK_PROMPT
+	// OldauthtokPrompt is the string used to prompt for the old
+	// authentication token.
+	OldauthtokPrompt Item = C.PAM_OLDAUTHTOK_PROMPT
+	// Host is the name of the host the application runs on.
+	Host Item = C.PAM_HOST
+)
+
+// platformItems are the items that are specific to this platform.
+var platformItems = []Item{Repository, AuthtokPrompt, OldauthtokPrompt, Host}
+
+// nonStringItems are the items that don't hold a string.
+var nonStringItems = map[Item]bool{
+	Repository: true,
+}
+
+// moduleItems are the items that only the modules are allowed to set, there
+// are none as OpenPAM lets the applications set the authentication tokens.
+var moduleItems = map[Item]bool{}
+
+// Feature is an OpenPAM feature that can be toggled.
+type Feature int
+
+// OpenPAM features.
+const (
+	// RestrictServiceName disallows path separators in service names.
+	RestrictServiceName Feature = C.OPENPAM_RESTRICT_SERVICE_NAME
+	// VerifyPolicyFile checks the ownership and permissions of the policy
+	// files.
+	VerifyPolicyFile Feature = C.OPENPAM_VERIFY_POLICY_FILE
+	// RestrictModuleName disallows path separators in module names.
+	RestrictModuleName Feature = C.OPENPAM_RESTRICT_MODULE_NAME
+	// VerifyModuleFile checks the ownership and permissions of the modules.
+	VerifyModuleFile Feature = C.OPENPAM_VERIFY_MODULE_FILE
+	// FallbackToOther uses the "other" policy for the facilities that the
+	// service policy doesn't define.
+	FallbackToOther Feature = C.OPENPAM_FALLBACK_TO_OTHER
+)
+
+// GetFeature reports whether an OpenPAM feature is enabled. Features are
+// global to the process.
+func GetFeature(f Feature) (bool, error) {
+	var onoff C.int
+	if r := C.openpam_get_feature(C.int(f), &onoff); r != success {
+		return false, Error(r)
+	}
+	return onoff != 0, nil
+}
+
+// SetFeature enables or disables an OpenPAM feature. Features are global to
+// the process, so this affects all the transactions.
+func SetFeature(f Feature, on bool) error {
+	var onoff C.int
+	if on {
+		onoff = 1
+	}
+	if r := C.openpam_set_feature(C.int(f), onoff); r != success {
+		return Error(r)
+	}
+	return nil
+}
+
+// SetOption sets an option of the module that is currently being invoked,
+// so it's only meaningful from a conversation handler.
+func (t *Transaction) SetOption(option, value string) error {
+	coption := C.CString(option)
+	defer C.free(unsafe.Pointer(coption))
+	cvalue := C.CString(value)
+	defer C.free(unsafe.Pointer(cvalue))
+	return t.handlePamStatus(C.openpam_set_option(t.handle, coption, cvalue))
+}
+
+// GetOption returns an option of the module that is currently being
+// invoked, reporting whether it's set. As SetOption, it's only meaningful
+// from a conversation handler.
+func (t *Transaction) GetOption(option string) (string, bool) {
+	coption := C.CString(option)
+	defer C.free(unsafe.Pointer(coption))
+	value := C.openpam_get_option(t.handle, coption)
+	if value == nil {
+		return "", false
+	}
+	return C.GoString(value), true
+}
@@ -0,0 +1,118 @@
+//go:build cgo && (freebsd || openpam)
+
+package pam
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenPAMCapabilities(t *testing.T) {
+	t.Parallel()
+	c := Capabilities()
+	if c.Implementation != OpenPAM {
+		t.Fatalf("capabilities #unexpected implementation: %v", c.Implementation)
+	}
+	for _, i := range []Item{Repository, AuthtokPrompt, OldauthtokPrompt, Host} {
+		if !c.HasItem(i) {
+			t.Fatalf("capabilities #expected item %d", i)
+		}
+	}
+	for _, e := range []Error{ErrBadItem, ErrBadHandle, ErrBadFeature,
+		ErrBadConstant, ErrDomainUnknown} {
+		if !c.HasError(e) {
+			t.Fatalf("capabilities #expected error %v", e)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	// Features are global, so this can't run in parallel.
+	on, err := GetFeature(FallbackToOther)
+	if err != nil {
+		t.Fatalf("getfeature #error: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := SetFeature(FallbackToOther, on); err != nil {
+			t.Fatalf("setfeature #error: %v", err)
+		}
+	})
+
+	for _, value := range []bool{!on, on} {
+		if err := SetFeature(FallbackToOther, value); err != nil {
+			t.Fatalf("setfeature #error: %v", err)
+		}
+		got, err := GetFeature(FallbackToOther)
+		if err != nil {
+			t.Fatalf("getfeature #error: %v", err)
+		}
+		if got != value {
+			t.Fatalf("getfeature #unexpected value: %v", got)
+		}
+	}
+
+	if _, err := GetFeature(Feature(-1)); err == nil {
+		t.Fatalf("getfeature #expected an error")
+	}
+	if err := SetFeature(Feature(-1), true); err == nil {
+		t.Fatalf("setfeature #expected an error")
+	}
+}
+
+func TestOpenPAMItems(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	for _, i := range []Item{AuthtokPrompt, OldauthtokPrompt, Host, Authtok, Oldauthtok} {
+		if err := tx.SetItem(i, "value"); err != nil {
+			t.Fatalf("setitem(%d) #error: %v", i, err)
+		}
+		if v, ok, err := tx.LookupItem(i); err != nil || !ok || v != "value" {
+			t.Fatalf("lookupitem(%d) #unexpected: %q, %v, %v", i, v, ok, err)
+		}
+	}
+	if err := tx.SetItem(Repository, "files"); !errors.Is(err, ErrBadItem) {
+		t.Fatalf("setitem #unexpected error: %v", err)
+	}
+	if _, _, err := tx.LookupItem(Repository); !errors.Is(err, ErrBadItem) {
+		t.Fatalf("lookupitem #unexpected error: %v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	// No module is being invoked, so there are no options to handle.
+	if v, ok := tx.GetOption("debug"); ok {
+		t.Fatalf("getoption #unexpected value: %q", v)
+	}
+	if err := tx.SetOption("debug", "1"); err == nil {
+		t.Fatalf("setoption #expected an error")
+	}
+}
+
+func TestFailure_001(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	if _, ok := tx.GetOption("debug"); ok {
+		t.Fatalf("getoption #expected no value")
+	}
+	if err := tx.SetOption("debug", "1"); err == nil {
+		t.Fatalf("setoption #expected an error")
+	}
+}
@@ -1,12 +1,21 @@
-//go:build linux
+//go:build linux && !openpam
 
 package pam
 
 /*
 #include <security/pam_appl.h>
+#include <stdlib.h>
+#include <string.h>
+
+void cb_pam_fail_delay(int retval, unsigned usec_delay, void *appdata_ptr);
 */
 import "C"
 
+import (
+	"time"
+	"unsafe"
+)
+
 // PAM Item types.
 const (
 	// FailDelay is the app supplied function to override failure delays.
@@ -18,3 +27,89 @@ const (
 	// AuthtokType is the type for pam_get_authtok.
 	AuthtokType Item = C.PAM_AUTHTOK_TYPE
 )
+
+// platformItems are the items that are specific to this platform.
+var platformItems = []Item{FailDelay, Xdisplay, Xauthdata, AuthtokType}
+
+// nonStringItems are the items that don't hold a string.
+var nonStringItems = map[Item]bool{
+	FailDelay: true,
+	Xauthdata: true,
+}
+
+// moduleItems are the items that only the modules are allowed to set.
+var moduleItems = map[Item]bool{
+	Authtok:    true,
+	Oldauthtok: true,
+}
+
+// SetFailDelayFunc sets the function that PAM calls, in place of waiting,
+// to apply the delay requested by the modules after a failure. The function
+// receives the status of the operation, that is 0 on success, and the delay.
+//
+// This allows the application to implement its own delay policy without
+// blocking. A nil function restores the default PAM behavior. Setting it
+// doesn't change the status passed to pam_end, so that it can be restored
+// after an operation.
+func (t *Transaction) SetFailDelayFunc(fn func(status Error, delay time.Duration)) error {
+	var delayFn unsafe.Pointer
+	if fn != nil {
+		delayFn = unsafe.Pointer(C.cb_pam_fail_delay)
+	}
+	if status := Error(C.pam_set_item(t.handle, C.PAM_FAIL_DELAY, delayFn)); status != success {
+		return status
+	}
+	if fn == nil {
+		t.callbacks.failDelay.Store(nil)
+	} else {
+		t.callbacks.failDelay.Store(&fn)
+	}
+	return nil
+}
+
+// FailDelayFunc returns the function set with SetFailDelayFunc, or nil if
+// PAM applies the delays itself.
+func (t *Transaction) FailDelayFunc() func(status Error, delay time.Duration) {
+	if t.callbacks == nil {
+		return nil
+	}
+	if fn := t.callbacks.failDelay.Load(); fn != nil {
+		return *fn
+	}
+	return nil
+}
+
+// SetXAuthData sets the X server authentication data, that is the name of
+// the authentication method and its data (for example an X cookie). It's
+// used by modules such as pam_systemd and pam_xauth.
+func (t *Transaction) SetXAuthData(name string, data []byte) error {
+	var xauth C.struct_pam_xauth_data
+	xauth.namelen = C.int(len(name))
+	xauth.name = C.CString(name)
+	defer C.free(unsafe.Pointer(xauth.name))
+	xauth.datalen = C.int(len(data))
+	xauth.data = (*C.char)(C.CBytes(data))
+	defer func() {
+		C.memset(unsafe.Pointer(xauth.data), 0, C.size_t(len(data)))
+		C.free(unsafe.Pointer(xauth.data))
+	}()
+	return t.handlePamStatus(C.pam_set_item(t.handle, C.PAM_XAUTHDATA,
+		unsafe.Pointer(&xauth)))
+}
+
+// GetXAuthData retrieves the X server authentication data.
+func (t *Transaction) GetXAuthData() (name string, data []byte, err error) {
+	var p unsafe.Pointer
+	err = t.handlePamStatus(C.pam_get_item(t.handle, C.PAM_XAUTHDATA, &p))
+	if err != nil || p == nil {
+		return "", nil, err
+	}
+	xauth := (*C.struct_pam_xauth_data)(p)
+	if xauth.namelen > 0 {
+		name = C.GoStringN(xauth.name, xauth.namelen)
+	}
+	if xauth.datalen > 0 {
+		data = C.GoBytes(unsafe.Pointer(xauth.data), xauth.datalen)
+	}
+	return name, data, nil
+}
@@ -1,9 +1,12 @@
-//go:build linux
+//go:build cgo && linux && !openpam
 
 package pam
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+	"time"
 )
 
 func Test_LinuxError(t *testing.T) {
@@ -21,6 +24,170 @@ func Test_LinuxError(t *testing.T) {
 	testError(t, statuses)
 }
 
+func TestFailDelayFunc(t *testing.T) {
+	t.Parallel()
+	if !CheckPamHasStartConfdir() {
+		t.Skip("this requires PAM with Conf dir support")
+	}
+	tx, err := StartConfDir("fail-delay-service", "testuser", StaticCredentials{}, "test-services")
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	var calls int
+	var status Error
+	var delay time.Duration
+	err = tx.SetFailDelayFunc(func(s Error, d time.Duration) {
+		calls++
+		status = s
+		delay = d
+	})
+	if err != nil {
+		t.Fatalf("setfaildelayfunc #error: %v", err)
+	}
+	if tx.FailDelayFunc() == nil {
+		t.Fatalf("faildelayfunc #unexpected nil function")
+	}
+
+	start := time.Now()
+	err = tx.Authenticate(0)
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("authenticate #unexpected delay: %v", elapsed)
+	}
+	if calls != 1 || status != ErrAuth {
+		t.Fatalf("faildelay #unexpected calls: %v, %v", calls, status)
+	}
+	// PAM randomizes the delay by up to 50%.
+	if delay < time.Second || delay > 3*time.Second {
+		t.Fatalf("faildelay #unexpected delay: %v", delay)
+	}
+
+	if err := tx.SetFailDelayFunc(nil); err != nil {
+		t.Fatalf("setfaildelayfunc #error: %v", err)
+	}
+	if status := Error(tx.lastStatus.Load()); status != ErrAuth {
+		t.Fatalf("setfaildelayfunc #unexpected status change: %v", status)
+	}
+	if tx.FailDelayFunc() != nil {
+		t.Fatalf("faildelayfunc #unexpected function")
+	}
+}
+
+func TestXAuthData(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	name, data, err := tx.GetXAuthData()
+	if err != nil {
+		t.Fatalf("getxauthdata #error: %v", err)
+	}
+	if name != "" || data != nil {
+		t.Fatalf("getxauthdata #unexpected data: %q, %v", name, data)
+	}
+
+	cookie := []byte{0xde, 0xad, 0x00, 0xbe, 0xef}
+	if err := tx.SetXAuthData("MIT-MAGIC-COOKIE-1", cookie); err != nil {
+		t.Fatalf("setxauthdata #error: %v", err)
+	}
+	name, data, err = tx.GetXAuthData()
+	if err != nil {
+		t.Fatalf("getxauthdata #error: %v", err)
+	}
+	if name != "MIT-MAGIC-COOKIE-1" || !bytes.Equal(data, cookie) {
+		t.Fatalf("getxauthdata #unexpected data: %q, %v", name, data)
+	}
+}
+
+func TestLookupItem(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	if v, ok, err := tx.LookupItem(Tty); err != nil || ok || v != "" {
+		t.Fatalf("lookupitem #unexpected: %q, %v, %v", v, ok, err)
+	}
+	if err := tx.SetItem(Tty, ""); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if v, ok, err := tx.LookupItem(Tty); err != nil || !ok || v != "" {
+		t.Fatalf("lookupitem #unexpected: %q, %v, %v", v, ok, err)
+	}
+	if err := tx.SetItem(Tty, "tty1"); err != nil {
+		t.Fatalf("setitem #error: %v", err)
+	}
+	if v, ok, err := tx.LookupItem(Tty); err != nil || !ok || v != "tty1" {
+		t.Fatalf("lookupitem #unexpected: %q, %v, %v", v, ok, err)
+	}
+	if err := tx.UnsetItem(Tty); err != nil {
+		t.Fatalf("unsetitem #error: %v", err)
+	}
+	if v, ok, err := tx.LookupItem(Tty); err != nil || ok || v != "" {
+		t.Fatalf("lookupitem #unexpected: %q, %v, %v", v, ok, err)
+	}
+	if v, err := tx.GetItem(Tty); err != nil || v != "" {
+		t.Fatalf("getitem #unexpected: %q, %v", v, err)
+	}
+}
+
+func TestBadItems(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	for _, i := range []Item{Authtok, Oldauthtok, FailDelay, Xauthdata} {
+		if err := tx.SetItem(i, "value"); !errors.Is(err, ErrBadItem) {
+			t.Fatalf("setitem(%d) #unexpected error: %v", i, err)
+		}
+		if err := tx.UnsetItem(i); !errors.Is(err, ErrBadItem) {
+			t.Fatalf("unsetitem(%d) #unexpected error: %v", i, err)
+		}
+	}
+	for _, i := range []Item{FailDelay, Xauthdata} {
+		if _, err := tx.GetItem(i); !errors.Is(err, ErrBadItem) {
+			t.Fatalf("getitem(%d) #unexpected error: %v", i, err)
+		}
+		if _, _, err := tx.LookupItem(i); !errors.Is(err, ErrBadItem) {
+			t.Fatalf("lookupitem(%d) #unexpected error: %v", i, err)
+		}
+	}
+	if err := tx.UnsetItem(Service); !errors.Is(err, ErrBadItem) {
+		t.Fatalf("unsetitem #unexpected error: %v", err)
+	}
+	if err := tx.SetItem(Ruser, "foo\x00bar"); !errors.Is(err, ErrBadItem) {
+		t.Fatalf("setitem #unexpected error: %v", err)
+	}
+	if v, ok, err := tx.LookupItem(Ruser); err != nil || ok {
+		t.Fatalf("lookupitem #unexpected: %q, %v, %v", v, ok, err)
+	}
+	if s, err := tx.GetItem(Service); err != nil || s != "passwd" {
+		t.Fatalf("getitem #unexpected: %q, %v", s, err)
+	}
+}
+
 func TestFailure_001(t *testing.T) {
 	t.Parallel()
 	tx := Transaction{}
@@ -110,3 +277,83 @@ func TestFailure_010(t *testing.T) {
 		t.Fatalf("end #unexpected error %v", err)
 	}
 }
+
+func TestFailure_011(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	err := tx.SetFailDelayFunc(func(Error, time.Duration) {})
+	if err == nil {
+		t.Fatalf("setfaildelayfunc #expected an error")
+	}
+}
+
+func TestFailure_012(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	err := tx.SetXAuthData("MIT-MAGIC-COOKIE-1", []byte{1})
+	if err == nil {
+		t.Fatalf("setxauthdata #expected an error")
+	}
+	_, _, err = tx.GetXAuthData()
+	if err == nil {
+		t.Fatalf("getxauthdata #expected an error")
+	}
+}
+
+func TestFailure_013(t *testing.T) {
+	t.Parallel()
+	tx := Transaction{}
+	err := tx.UnsetItem(User)
+	if err == nil {
+		t.Fatalf("unsetitem #expected an error")
+	}
+	_, _, err = tx.LookupItem(User)
+	if err == nil {
+		t.Fatalf("lookupitem #expected an error")
+	}
+}
+
+func TestLinuxCapabilities(t *testing.T) {
+	t.Parallel()
+	c := Capabilities()
+	for _, i := range []Item{FailDelay, Xdisplay, Xauthdata, AuthtokType} {
+		if !c.HasItem(i) {
+			t.Fatalf("capabilities #expected item %d", i)
+		}
+	}
+	for _, e := range []Error{ErrBadItem, ErrConvAgain, ErrIncomplete} {
+		if !c.HasError(e) {
+			t.Fatalf("capabilities #expected error %v", e)
+		}
+	}
+	for _, name := range []string{"pam_get_authtok", "pam_fail_delay"} {
+		if !c.Functions[name] {
+			t.Fatalf("capabilities #expected function %s", name)
+		}
+	}
+}
+
+func TestStrError(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	defer maybeEndTransaction(t, tx)
+	if err != nil {
+		t.Fatalf("start #error: %v", err)
+	}
+	ensureTransactionEnds(t, tx)
+
+	// Go programs don't set the locale, so Linux-PAM reports the same
+	// messages.
+	for _, e := range Capabilities().Errors {
+		if msg := tx.StrError(e); msg != e.Error() {
+			t.Fatalf("strerror #unexpected message for %d: %q", e, msg)
+		}
+	}
+
+	tx = &Transaction{}
+	if msg := tx.StrError(ErrAuth); msg != ErrAuth.Error() {
+		t.Fatalf("strerror #unexpected message: %q", msg)
+	}
+}
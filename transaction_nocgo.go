@@ -0,0 +1,261 @@
+//go:build !cgo
+
+package pam
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// success indicates a successful function return.
+const success = 0
+
+// Coversation handler style types, with the values used by Linux-PAM.
+const (
+	// PromptEchoOff indicates the conversation handler should obtain a
+	// string without echoing any text.
+	PromptEchoOff Style = 1
+	// PromptEchoOn indicates the conversation handler should obtain a
+	// string while echoing text.
+	PromptEchoOn Style = 2
+	// ErrorMsg indicates the conversation handler should display an
+	// error message.
+	ErrorMsg Style = 3
+	// TextInfo indicates the conversation handler should display some
+	// text.
+	TextInfo Style = 4
+	// BinaryPrompt indicates the conversation handler that should
+	// implement the private binary protocol.
+	BinaryPrompt Style = 7
+)
+
+// PAM Item types, with the values used by Linux-PAM.
+const (
+	// Service is the name which identifies the PAM stack.
+	Service Item = 1
+	// User identifies the username identity used by a service.
+	User Item = 2
+	// Tty is the terminal name.
+	Tty Item = 3
+	// Rhost is the requesting host name.
+	Rhost Item = 4
+	// Authtok is the currently active authentication token.
+	Authtok Item = 6
+	// Oldauthtok is the old authentication token.
+	Oldauthtok Item = 7
+	// Ruser is the requesting user name.
+	Ruser Item = 8
+	// UserPrompt is the string use to prompt for a username.
+	UserPrompt Item = 9
+	// FailDelay is the app supplied function to override failure delays.
+	FailDelay Item = 10
+	// Xdisplay is the X display name.
+	Xdisplay Item = 11
+	// Xauthdata is the X server authentication data.
+	Xauthdata Item = 12
+	// AuthtokType is the type for pam_get_authtok.
+	AuthtokType Item = 13
+)
+
+// platformItems are the items that are specific to this platform.
+var platformItems = []Item{FailDelay, Xdisplay, Xauthdata, AuthtokType}
+
+// nonStringItems are the items that don't hold a string.
+var nonStringItems = map[Item]bool{
+	FailDelay: true,
+	Xauthdata: true,
+}
+
+// moduleItems are the items that only the modules are allowed to set.
+var moduleItems = map[Item]bool{
+	Authtok:    true,
+	Oldauthtok: true,
+}
+
+// PAM Flag types, with the values used by Linux-PAM.
+const (
+	// Silent indicates that no messages should be emitted.
+	Silent Flags = 0x8000
+	// DisallowNullAuthtok indicates that authorization should fail
+	// if the user does not have a registered authentication token.
+	DisallowNullAuthtok Flags = 0x0001
+	// EstablishCred indicates that credentials should be established
+	// for the user.
+	EstablishCred Flags = 0x0002
+	// DeleteCred indicates that credentials should be deleted.
+	DeleteCred Flags = 0x0004
+	// ReinitializeCred indicates that credentials should be fully
+	// reinitialized.
+	ReinitializeCred Flags = 0x0008
+	// RefreshCred indicates that the lifetime of existing credentials
+	// should be extended.
+	RefreshCred Flags = 0x0010
+	// ChangeExpiredAuthtok indicates that the authentication token
+	// should be changed if it has expired.
+	ChangeExpiredAuthtok Flags = 0x0020
+)
+
+// implementation is the PAM implementation the package is built with.
+const implementation = UnknownImplementation
+
+// headersVersion returns the version declared by the PAM headers, if any.
+func headersVersion() string {
+	return ""
+}
+
+// libraryPath returns the path of the PAM library in use, with the symbolic
+// links resolved, or an empty string if it can't be found.
+func libraryPath() string {
+	return ""
+}
+
+// lookupSymbol returns the address of a symbol provided by the PAM library,
+// or nil if it's not available.
+func lookupSymbol(name string) unsafe.Pointer {
+	return nil
+}
+
+// peekItem returns the value of an item, without affecting the status of
+// the transaction.
+func (t *Transaction) peekItem(i Item) string {
+	return ""
+}
+
+// Transaction is the application's handle for a PAM transaction.
+//
+// Without cgo there's no PAM library to use, so a transaction can't be
+// started and all its operations fail with ErrUnsupported.
+type Transaction struct {
+	lastStatus atomic.Int32
+	callbacks  *callbacks
+}
+
+// Start initiates a new PAM transaction. Without cgo it always fails with
+// ErrUnsupported.
+func Start(service, user string, handler ConversationHandler) (*Transaction, error) {
+	return nil, ErrUnsupported
+}
+
+// StartFunc registers the handler func as a conversation handler and starts
+// the transaction (see Start() documentation).
+func StartFunc(service, user string, handler func(Style, string) (string, error)) (*Transaction, error) {
+	return Start(service, user, ConversationFunc(handler))
+}
+
+// StartConfDir initiates a new PAM transaction using the services defined
+// in confDir. Without cgo it always fails with ErrUnsupported.
+func StartConfDir(service, user string, handler ConversationHandler, confDir string) (*Transaction, error) {
+	return nil, ErrUnsupported
+}
+
+// End cleans up the PAM handle and deletes the callback function.
+// It must be called when done with the transaction.
+func (t *Transaction) End() error {
+	return nil
+}
+
+// StrError returns the message that PAM provides for the status, that
+// without cgo is the one returned by Error.Error.
+func (t *Transaction) StrError(status Error) string {
+	return status.Error()
+}
+
+// SetItem sets a PAM information item.
+func (t *Transaction) SetItem(i Item, item string) error {
+	return ErrUnsupported
+}
+
+// UnsetItem clears a PAM information item, so that it's not set anymore.
+func (t *Transaction) UnsetItem(i Item) error {
+	return ErrUnsupported
+}
+
+// GetItem retrieves a PAM information item. Items that are not set are
+// returned as an empty string, use LookupItem to tell them apart.
+func (t *Transaction) GetItem(i Item) (string, error) {
+	return "", ErrUnsupported
+}
+
+// LookupItem retrieves a PAM information item, reporting whether it's set.
+func (t *Transaction) LookupItem(i Item) (string, bool, error) {
+	return "", false, ErrUnsupported
+}
+
+// SetFailDelayFunc sets the function that PAM calls, in place of waiting,
+// to apply the delay requested by the modules after a failure.
+func (t *Transaction) SetFailDelayFunc(fn func(status Error, delay time.Duration)) error {
+	return ErrUnsupported
+}
+
+// FailDelayFunc returns the function set with SetFailDelayFunc, or nil if
+// PAM applies the delays itself.
+func (t *Transaction) FailDelayFunc() func(status Error, delay time.Duration) {
+	return nil
+}
+
+// SetXAuthData sets the X server authentication data.
+func (t *Transaction) SetXAuthData(name string, data []byte) error {
+	return ErrUnsupported
+}
+
+// GetXAuthData retrieves the X server authentication data.
+func (t *Transaction) GetXAuthData() (name string, data []byte, err error) {
+	return "", nil, ErrUnsupported
+}
+
+// Authenticate is used to authenticate the user.
+func (t *Transaction) Authenticate(f Flags) error {
+	return ErrUnsupported
+}
+
+// SetCred is used to establish, maintain and delete the credentials of a
+// user.
+func (t *Transaction) SetCred(f Flags) error {
+	return ErrUnsupported
+}
+
+// AcctMgmt is used to determine if the user's account is valid.
+func (t *Transaction) AcctMgmt(f Flags) error {
+	return ErrUnsupported
+}
+
+// ChangeAuthTok is used to change the authentication token.
+func (t *Transaction) ChangeAuthTok(f Flags) error {
+	return ErrUnsupported
+}
+
+// OpenSession sets up a user session for an authenticated user.
+func (t *Transaction) OpenSession(f Flags) error {
+	return ErrUnsupported
+}
+
+// CloseSession closes a previously opened session.
+func (t *Transaction) CloseSession(f Flags) error {
+	return ErrUnsupported
+}
+
+// PutEnv adds or changes the value of PAM environment variables.
+func (t *Transaction) PutEnv(nameval string) error {
+	return ErrUnsupported
+}
+
+// GetEnv is used to retrieve a PAM environment variable.
+func (t *Transaction) GetEnv(name string) string {
+	return ""
+}
+
+// GetEnvList returns a copy of the PAM environment as a map.
+func (t *Transaction) GetEnvList() (map[string]string, error) {
+	return nil, ErrUnsupported
+}
+
+// CheckPamHasStartConfdir reports whether PAM supports pam_system_confdir.
+func CheckPamHasStartConfdir() bool {
+	return false
+}
+
+// CheckPamHasBinaryProtocol reports whether PAM supports PAM_BINARY_PROMPT.
+func CheckPamHasBinaryProtocol() bool {
+	return false
+}
@@ -0,0 +1,41 @@
+//go:build !cgo
+
+package pam
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNoCgo(t *testing.T) {
+	t.Parallel()
+	tx, err := StartFunc("passwd", "test", func(s Style, msg string) (string, error) {
+		return "", nil
+	})
+	if !errors.Is(err, ErrUnsupported) || tx != nil {
+		t.Fatalf("start #unexpected result: %v, %v", tx, err)
+	}
+	_, err = StartConfDir("passwd", "test", StaticCredentials{}, t.TempDir())
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("startconfdir #unexpected error: %v", err)
+	}
+
+	tx = &Transaction{}
+	if err := tx.Authenticate(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("authenticate #unexpected error: %v", err)
+	}
+	if _, err := tx.GetItem(User); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("getitem #unexpected error: %v", err)
+	}
+	if err := tx.End(); err != nil {
+		t.Fatalf("end #unexpected error: %v", err)
+	}
+	if CheckPamHasStartConfdir() || CheckPamHasBinaryProtocol() {
+		t.Fatalf("check #unexpected support")
+	}
+
+	c := Capabilities()
+	if c.Implementation != UnknownImplementation || c.HasFunction("pam_start") {
+		t.Fatalf("capabilities #unexpected report: %#v", c)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build cgo && !linux && !freebsd && !openpam
+
+package pam
+
+// platformItems are the items that are specific to this platform.
+var platformItems []Item
+
+// nonStringItems are the items that don't hold a string.
+var nonStringItems = map[Item]bool{}
+
+// moduleItems are the items that only the modules are allowed to set.
+var moduleItems = map[Item]bool{}